@@ -0,0 +1,258 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/StevenACoffman/teamboard/pkg/config"
+	"github.com/StevenACoffman/teamboard/pkg/github"
+	"github.com/StevenACoffman/teamboard/pkg/logging"
+	"github.com/StevenACoffman/teamboard/pkg/types"
+)
+
+// ANSI escape codes for the table output
+const (
+	colorReset  = "\033[0m"
+	colorBold   = "\033[1m"
+	colorFaint  = "\033[2m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorCyan   = "\033[36m"
+)
+
+var (
+	listFormat  string
+	listReasons []string
+	listSort    string
+)
+
+// listCmd prints the board to the terminal instead of serving it
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the open pull requests for you and your team",
+	Long: `List prints the same pull requests as the board, most recent first,
+as a table (the default), as JSON, or as tab separated values.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load(viper.GetViper())
+		if err != nil {
+			return err
+		}
+		orgs := cfg.DefaultOrgs()
+		if len(orgs) == 0 || (len(orgs) == 1 && cfg.Team == "") {
+			return fmt.Errorf("both --org and --team are required, unless they are in the config file")
+		}
+		reasons := append([]types.Reason(nil), types.Reasons...)
+		for _, q := range cfg.Queries {
+			reasons = append(reasons, types.Reason(q.Name))
+		}
+		for _, reason := range listReasons {
+			if !knownReason(reasons, types.Reason(reason)) {
+				return fmt.Errorf("unknown --reason %q, must be one of %v", reason, reasons)
+			}
+		}
+		listOrder, err := github.ParseSort(listSort, "")
+		if err != nil {
+			return fmt.Errorf("--sort: %w", err)
+		}
+
+		// logs go to stderr, so they don't get mixed up with the list
+		logger := newLogger(cfg, cmd.ErrOrStderr())
+		graphqlClient, _, err := newGraphQLClient(cfg, logger)
+		if err != nil {
+			return err
+		}
+
+		ctx := logging.NewContext(context.Background(), logger)
+		myLogin, ok := cfg.FixedLogin()
+		if !ok {
+			myLogin, err = github.GetLogin(ctx, graphqlClient)
+			if err != nil {
+				return err
+			}
+		}
+		board, err := github.GetBoard(ctx, graphqlClient, myLogin, orgs, cfg.Team, cfg.SearchOptions())
+		if err != nil {
+			return err
+		}
+
+		var filter github.Filter
+		for _, reason := range listReasons {
+			filter.Reasons = append(filter.Reasons, types.Reason(reason))
+		}
+		pulls := filter.Pulls(board.Pulls())
+		sort.SliceStable(pulls, func(i, j int) bool {
+			// results in most recent to oldest
+			return pulls[i].CreatedAt.After(pulls[j].CreatedAt)
+		})
+		listOrder.Pulls(pulls)
+
+		switch listFormat {
+		case "json":
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			return enc.Encode(pulls)
+		case "tsv":
+			return writeTSV(cmd.OutOrStdout(), pulls)
+		case "table":
+			return writeTable(cmd.OutOrStdout(), pulls, useColor(cmd.OutOrStdout()))
+		default:
+			return fmt.Errorf("unknown --format %q, must be table, json or tsv", listFormat)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+
+	listCmd.Flags().StringVar(&listFormat, "format", "table", "output format: table, json or tsv")
+	listCmd.Flags().StringSliceVar(&listReasons, "reason", nil,
+		"only list pull requests on the board for these reasons, e.g. review-requested,team-authored")
+	listCmd.Flags().StringVar(&listSort, "sort", "",
+		"sort by age, size, repo, author or updated, instead of most recent first")
+}
+
+func knownReason(reasons []types.Reason, reason types.Reason) bool {
+	for _, r := range reasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// useColor reports whether w is a terminal, and $NO_COLOR isn't set.
+func useColor(w io.Writer) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// writeTable writes pulls as aligned columns. The padding is worked out on
+// the plain text before it is colored, since the escape codes take up bytes
+// but no room on screen.
+func writeTable(w io.Writer, pulls []types.PullRequest, color bool) error {
+	header := []string{"REPO", "NUMBER", "TITLE", "AUTHOR", "AGE", "SIZE", "REASON"}
+	colors := []string{colorCyan, colorFaint, "", "", colorYellow, "", ""}
+
+	rows := [][]string{header}
+	for _, pull := range pulls {
+		title := pull.Title
+		if pull.IsDraft {
+			title = "[draft] " + title
+		}
+		pullAge := pull.Age
+		if pull.Overdue() {
+			pullAge += " overdue"
+		}
+		rows = append(rows, []string{
+			pull.Repository.NameWithOwner,
+			"#" + strconv.Itoa(pull.Number),
+			truncate(title, 60),
+			pull.Author.Login,
+			pullAge,
+			fmt.Sprintf("+%d -%d", pull.Additions, pull.Deletions),
+			reasonLabels(pull),
+		})
+	}
+
+	widths := make([]int, len(header))
+	for _, row := range rows {
+		for i, cell := range row {
+			if n := utf8.RuneCountInString(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	for r, row := range rows {
+		var line strings.Builder
+		for i, cell := range row {
+			padded := cell
+			if i < len(row)-1 {
+				padded += strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)+2)
+			}
+			switch {
+			case !color:
+				line.WriteString(padded)
+			case r == 0:
+				line.WriteString(colorBold + padded + colorReset)
+			case i == 5:
+				// additions in green, deletions in red
+				plus, minus := fmt.Sprintf("+%d", pulls[r-1].Additions), fmt.Sprintf("-%d", pulls[r-1].Deletions)
+				line.WriteString(colorGreen + plus + colorReset + " " +
+					colorRed + minus + colorReset + padded[len(plus)+1+len(minus):])
+			case colors[i] != "":
+				line.WriteString(colors[i] + padded + colorReset)
+			default:
+				line.WriteString(padded)
+			}
+		}
+		if _, err := fmt.Fprintln(w, strings.TrimRight(line.String(), " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeTSV(w io.Writer, pulls []types.PullRequest) error {
+	_, err := fmt.Fprintln(w, "repo\tnumber\ttitle\tauthor\tcreated_at\tadditions\tdeletions\treasons\turl")
+	if err != nil {
+		return err
+	}
+	for _, pull := range pulls {
+		var reasons []string
+		for _, reason := range pull.Reasons {
+			reasons = append(reasons, string(reason))
+		}
+		_, err = fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%d\t%d\t%s\t%s\n",
+			pull.Repository.NameWithOwner,
+			pull.Number,
+			strings.ReplaceAll(pull.Title, "\t", " "),
+			pull.Author.Login,
+			pull.CreatedAt.Format(time.RFC3339),
+			pull.Additions,
+			pull.Deletions,
+			strings.Join(reasons, ","),
+			pull.Url,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func reasonLabels(pull types.PullRequest) string {
+	var labels []string
+	for _, reason := range pull.Reasons {
+		labels = append(labels, reason.Label())
+	}
+	return strings.Join(labels, ", ")
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
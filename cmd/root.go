@@ -4,17 +4,24 @@ import (
 	_ "embed"
 	"fmt"
 	"github.com/Khan/genqlient/graphql"
+	"github.com/StevenACoffman/teamboard/pkg/cache"
+	"github.com/StevenACoffman/teamboard/pkg/config"
+	"github.com/StevenACoffman/teamboard/pkg/github"
+	"github.com/StevenACoffman/teamboard/pkg/logging"
 	"github.com/StevenACoffman/teamboard/pkg/middleware"
 	"github.com/StevenACoffman/teamboard/pkg/server"
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	"log"
+	"io"
 	"os"
 )
 
 var cfgFile string
 
+// configFileUsed is the config file that was read, if any.
+var configFileUsed string
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "teamboard",
@@ -26,46 +33,65 @@ var rootCmd = &cobra.Command{
 		var err error
 		defer func() {
 			if err != nil {
-				fmt.Println(err)
+				// the config may not have been read, so it's the default logger
+				logging.Default.Error("unable to start", "error", err)
 				os.Exit(1)
 			}
 		}()
 
-		key := os.Getenv("GITHUB_TOKEN")
-		if key == "" {
-			err = fmt.Errorf("must set GITHUB_TOKEN=<github token>")
+		var cfg config.Config
+		cfg, err = config.Load(viper.GetViper())
+		if err != nil {
 			return
 		}
 
-		httpClient := middleware.NewBearerAuthHTTPClient(key)
+		logger := newLogger(cfg, os.Stderr)
 
-		graphqlClient := graphql.NewClient("https://api.github.com/graphql", httpClient)
+		var client graphql.Client
+		var limiter *middleware.RateLimitRoundTripper
+		client, limiter, err = newGraphQLClient(cfg, logger)
+		if err != nil {
+			return
+		}
+		graphqlClient := cache.NewClient(client, cfg.TTLs())
 
 		// App Starting
-		logger := log.New(os.Stdout,
-			"INFO: ",
-			log.Ldate|log.Ltime|log.Lshortfile)
-		logger.Printf("main : Started")
-		err = server.RunServer(logger, graphqlClient)
+		logger.Info("started")
+		err = server.RunServer(logger, graphqlClient, cfg, limiter)
 		if err == nil {
-			logger.Println("finished clean")
+			logger.Info("finished clean")
 			os.Exit(0)
 		} else {
-			logger.Printf("Got error: %v", err)
+			logger.Error("stopped", "error", err)
 			os.Exit(1)
 		}
-
-		if err != nil {
-			fmt.Println("ERROR", err)
-		}
 	},
 }
 
+// newGraphQLClient returns a client for the GitHub GraphQL API,
+// authenticated with the token or GitHub App from the config, along with the round
+// tripper keeping track of its rate limit. Failed requests are logged
+// to logger.
+func newGraphQLClient(
+	cfg config.Config,
+	logger *logging.Logger,
+) (graphql.Client, *middleware.RateLimitRoundTripper, error) {
+	if cfg.Token == "" && cfg.AppAuth() == nil {
+		return nil, nil, fmt.Errorf("must set GITHUB_TOKEN=<github token>, or token or app in the config file")
+	}
+
+	opts := cfg.ClientOptions()
+	opts.Logger = logger
+	httpClient, limiter := middleware.NewGitHubHTTPClient(cfg.Token, opts)
+
+	return graphql.NewClient("https://api.github.com/graphql", httpClient), limiter, nil
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
+		logging.Default.Error("command failed", "error", err)
 		os.Exit(1)
 	}
 }
@@ -83,6 +109,36 @@ func init() {
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	rootCmd.Flags().String("addr", config.DefaultAddr, "address to serve the board on, if unset :$PORT is used when set")
+	rootCmd.PersistentFlags().String("org", "", "default GitHub organization, e.g. Khan")
+	rootCmd.PersistentFlags().StringSlice("orgs", nil,
+		"default GitHub organizations to show together on one board, instead of --org")
+	rootCmd.PersistentFlags().String("team", "", "default team slug within the organization, e.g. districts")
+	rootCmd.PersistentFlags().String("login", "",
+		"GitHub login the board is for, instead of whoever the token belongs to")
+	rootCmd.PersistentFlags().StringSlice("repos", nil,
+		"extra repositories whose open pull requests to show, e.g. Khan/webapp")
+	rootCmd.PersistentFlags().Int("max-pages", github.DefaultMaxPages,
+		"most pages of 100 pull requests to fetch for each search")
+	rootCmd.PersistentFlags().Bool("child-teams", false,
+		"include members of child teams, recursively, as teammates")
+	rootCmd.PersistentFlags().Bool("drafts", false,
+		"include draft pull requests by the team, in watched repos and from custom queries")
+	rootCmd.PersistentFlags().Bool("no-redact", false,
+		"log the token and other credentials in failed requests, for debugging locally")
+	rootCmd.PersistentFlags().String("log-format", string(logging.FormatLogfmt),
+		"how to write logs: logfmt or json")
+	rootCmd.PersistentFlags().String("log-level", logging.LevelInfo.String(),
+		"least important logs to write: debug, info, warn or error")
+
+	// Every flag can also be set with a TEAMBOARD_ environment variable,
+	// or in the config file, e.g. child-teams: true
+	// A flag that was actually passed wins over both.
+	_ = viper.BindPFlag("addr", rootCmd.Flags().Lookup("addr"))
+	for _, name := range []string{"org", "orgs", "team", "login", "repos", "max-pages", "child-teams", "drafts", "no-redact",
+		"log-format", "log-level"} {
+		_ = viper.BindPFlag(name, rootCmd.PersistentFlags().Lookup(name))
+	}
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -94,7 +150,7 @@ func initConfig() {
 		// Find home directory.
 		home, err := homedir.Dir()
 		if err != nil {
-			fmt.Println(err)
+			logging.Default.Error("unable to find home directory", "error", err)
 			os.Exit(1)
 		}
 
@@ -103,11 +159,21 @@ func initConfig() {
 		viper.SetConfigName(".teamboard")
 	}
 
-	viper.AutomaticEnv() // read in environment variables that match
+	config.SetDefaults(viper.GetViper()) // and read in environment variables that match
 
-	// If a config file is found, read it in.
+	// If a config file is found, read it in. Which one is logged once
+	// the logger is set up from it.
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		configFileUsed = viper.ConfigFileUsed()
 	}
 }
 
+// newLogger is the logger the config asks for, writing to w, which should
+// be stderr so logs never mix with a command's output.
+func newLogger(cfg config.Config, w io.Writer) *logging.Logger {
+	logger := cfg.Logger(w)
+	if configFileUsed != "" {
+		logger.Info("using config file", "path", configFileUsed)
+	}
+	return logger
+}
@@ -0,0 +1,208 @@
+// package cache - a caching graphql.Client, so reloading the board
+// doesn't ask GitHub the same questions over and over.
+
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Khan/genqlient/graphql"
+
+	"github.com/StevenACoffman/teamboard/pkg/logging"
+)
+
+// DefaultTTL is how long responses are cached for, for operations
+// that aren't in the TTLs passed to NewClient.
+const DefaultTTL = time.Minute
+
+// DefaultTTLs are per-operation TTLs suited to how often the answers
+// actually change. Who you are and which orgs you are in hardly ever
+// do; open pull requests change all the time.
+var DefaultTTLs = map[string]time.Duration{
+	"MyLogin":     12 * time.Hour,
+	"MyOrgs":      6 * time.Hour,
+	"MyTeams":     time.Hour,
+	"TeamMembers": time.Hour,
+	"ChildTeams":  time.Hour,
+	"MyBatch":     time.Minute,
+	"SearchPulls": time.Minute,
+}
+
+type refreshKey struct{}
+
+// WithRefresh returns a context that makes the Client skip cached
+// responses, and fetch (and cache) fresh ones instead.
+func WithRefresh(ctx context.Context) context.Context {
+	return context.WithValue(ctx, refreshKey{}, true)
+}
+
+// refreshing reports whether ctx came from WithRefresh.
+func refreshing(ctx context.Context) bool {
+	refresh, _ := ctx.Value(refreshKey{}).(bool)
+	return refresh
+}
+
+// Stats are the cache hit metrics for a single operation.
+type Stats struct {
+	Operation string `json:"operation"`
+	Hits      int64  `json:"hits"`
+	Misses    int64  `json:"misses"`
+	Refreshes int64  `json:"refreshes"`
+}
+
+type entry struct {
+	data    []byte
+	expires time.Time
+}
+
+// Client is a graphql.Client that caches the responses of the one it wraps,
+// keyed by operation name and variables.
+type Client struct {
+	next       graphql.Client
+	ttls       map[string]time.Duration
+	defaultTTL time.Duration
+
+	mu      sync.Mutex
+	entries map[string]entry
+	stats   map[string]*Stats
+}
+
+// NewClient wraps next in a cache. Operations without an entry in ttls are
+// cached for DefaultTTL, and a TTL of zero or less turns caching off for
+// that operation.
+func NewClient(next graphql.Client, ttls map[string]time.Duration) *Client {
+	if ttls == nil {
+		ttls = DefaultTTLs
+	}
+	return &Client{
+		next:       next,
+		ttls:       ttls,
+		defaultTTL: DefaultTTL,
+		entries:    make(map[string]entry),
+		stats:      make(map[string]*Stats),
+	}
+}
+
+// MakeRequest satisfies the graphql.Client interface
+func (c *Client) MakeRequest(
+	ctx context.Context,
+	opName string,
+	query string,
+	retval interface{},
+	variables map[string]interface{},
+) error {
+	ttl, ok := c.ttls[opName]
+	if !ok {
+		ttl = c.defaultTTL
+	}
+	if ttl <= 0 {
+		return c.next.MakeRequest(ctx, opName, query, retval, variables)
+	}
+
+	key, err := cacheKey(opName, variables)
+	if err != nil {
+		return c.next.MakeRequest(ctx, opName, query, retval, variables)
+	}
+
+	logger := logging.FromContext(ctx).With("operation", opName)
+	refresh := ctx != nil && refreshing(ctx)
+	if !refresh {
+		if data, ok := c.get(opName, key); ok {
+			logger.Debug("cache hit")
+			return json.Unmarshal(data, retval)
+		}
+	}
+	logger.Debug("cache miss", "refresh", refresh)
+
+	err = c.next.MakeRequest(ctx, opName, query, retval, variables)
+	if err != nil {
+		// don't cache errors, the next request may well succeed
+		return err
+	}
+
+	data, err := json.Marshal(retval)
+	if err == nil {
+		c.set(opName, key, data, ttl, refresh)
+	}
+	return nil
+}
+
+// Stats returns the hit metrics for every operation seen so far,
+// sorted by operation name.
+func (c *Client) Stats() []Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := make([]Stats, 0, len(c.stats))
+	for _, s := range c.stats {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Operation < stats[j].Operation
+	})
+	return stats
+}
+
+// Purge forgets every cached response.
+func (c *Client) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]entry)
+}
+
+func (c *Client) get(opName, key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.statsFor(opName)
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		stats.Misses++
+		return nil, false
+	}
+	stats.Hits++
+	return e.data, true
+}
+
+func (c *Client) set(opName, key string, data []byte, ttl time.Duration, refresh bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if refresh {
+		c.statsFor(opName).Refreshes++
+	}
+
+	// sweep out anything stale while we are here,
+	// so cursors for pages nobody asks for again don't pile up
+	now := time.Now()
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = entry{data: data, expires: now.Add(ttl)}
+}
+
+// statsFor must be called with c.mu held.
+func (c *Client) statsFor(opName string) *Stats {
+	s, ok := c.stats[opName]
+	if !ok {
+		s = &Stats{Operation: opName}
+		c.stats[opName] = s
+	}
+	return s
+}
+
+// cacheKey is the operation name followed by the variables as JSON,
+// which encoding/json writes with the map keys sorted.
+func cacheKey(opName string, variables map[string]interface{}) (string, error) {
+	b, err := json.Marshal(variables)
+	if err != nil {
+		return "", err
+	}
+	return opName + ":" + string(b), nil
+}
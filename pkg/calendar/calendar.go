@@ -0,0 +1,161 @@
+// package calendar - business time, for ages and SLAs that only count the
+// hours people are actually working.
+
+package calendar
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxDays is how far Add will look ahead for working time, so a calendar
+// with hardly any working days can't loop forever.
+const maxDays = 10 * 366
+
+// Calendar is when people work: which days, between which hours, in which
+// time zone, and which days are holidays.
+type Calendar struct {
+	// Location is the time zone of anyone not in Zones.
+	Location *time.Location
+	// Workdays are the days of the week people work.
+	Workdays []time.Weekday
+	// Start and End are the working hours, as the time since midnight.
+	Start time.Duration
+	End   time.Duration
+	// Holidays are the days nobody works, like 2021-12-25
+	Holidays []string
+	// Zones are the time zones of particular people, by login.
+	Zones map[string]*time.Location
+}
+
+// Always is the calendar where every hour of every day counts.
+var Always = &Calendar{
+	Location: time.UTC,
+	Workdays: []time.Weekday{
+		time.Sunday,
+		time.Monday,
+		time.Tuesday,
+		time.Wednesday,
+		time.Thursday,
+		time.Friday,
+		time.Saturday,
+	},
+	End: 24 * time.Hour,
+}
+
+// For is the calendar in login's time zone, if it is known.
+func (c *Calendar) For(login string) *Calendar {
+	for name, loc := range c.Zones {
+		if strings.EqualFold(name, login) {
+			person := *c
+			person.Location = loc
+			return &person
+		}
+	}
+	return c
+}
+
+// Day is how long a working day is.
+func (c *Calendar) Day() time.Duration {
+	return c.End - c.Start
+}
+
+// Elapsed is how much working time there is between from and to.
+func (c *Calendar) Elapsed(from, to time.Time) time.Duration {
+	var elapsed time.Duration
+	for day := c.midnight(from); day.Before(to); day = day.AddDate(0, 0, 1) {
+		start, end, ok := c.hours(day)
+		if !ok {
+			continue
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			elapsed += end.Sub(start)
+		}
+	}
+	return elapsed
+}
+
+// Add is when d of working time after from will have passed.
+func (c *Calendar) Add(from time.Time, d time.Duration) time.Time {
+	if d <= 0 {
+		return from
+	}
+	day := c.midnight(from)
+	for i := 0; i < maxDays; i, day = i+1, day.AddDate(0, 0, 1) {
+		start, end, ok := c.hours(day)
+		if !ok || !end.After(from) {
+			continue
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if left := end.Sub(start); d > left {
+			d -= left
+			continue
+		}
+		return start.Add(d)
+	}
+	return day
+}
+
+// Format is a short, rough, human readable amount of working time, like
+// 5m, 3h or 12d, where a day is a working day.
+func (c *Calendar) Format(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < c.Day():
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d/c.Day()))
+	}
+}
+
+// midnight is the start of the day t is in, in the calendar's time zone.
+func (c *Calendar) midnight(t time.Time) time.Time {
+	t = t.In(c.Location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, c.Location)
+}
+
+// hours are the working hours of the day starting at midnight,
+// if it is a working day.
+func (c *Calendar) hours(midnight time.Time) (time.Time, time.Time, bool) {
+	if !c.workday(midnight) {
+		return time.Time{}, time.Time{}, false
+	}
+	return c.clock(midnight, c.Start), c.clock(midnight, c.End), true
+}
+
+// clock is the time on the clock sinceMidnight after the midnight that
+// starts a day. On the days the clocks change, that isn't the same as
+// adding it to midnight.
+func (c *Calendar) clock(midnight time.Time, sinceMidnight time.Duration) time.Time {
+	year, month, day := midnight.Date()
+	return time.Date(year, month, day,
+		int(sinceMidnight/time.Hour),
+		int(sinceMidnight%time.Hour/time.Minute),
+		int(sinceMidnight%time.Minute/time.Second),
+		0, c.Location)
+}
+
+func (c *Calendar) workday(midnight time.Time) bool {
+	date := midnight.Format("2006-01-02")
+	for _, holiday := range c.Holidays {
+		if holiday == date {
+			return false
+		}
+	}
+	for _, weekday := range c.Workdays {
+		if weekday == midnight.Weekday() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("no time zone data for %s: %v", name, err)
+	}
+	return loc
+}
+
+// testCalendars are 9 to 5 in New York: on weekdays, with a holiday on
+// 2021-07-05, and every day, for the days the clocks change, which are
+// Sundays.
+func testCalendars(t *testing.T) (weekdays, everyDay *Calendar) {
+	newYork := mustLoad(t, "America/New_York")
+	weekdays = &Calendar{
+		Location: newYork,
+		Workdays: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		Start:    9 * time.Hour,
+		End:      17 * time.Hour,
+		Holidays: []string{"2021-07-05"},
+		Zones:    map[string]*time.Location{"octocat": mustLoad(t, "Europe/London")},
+	}
+	everyDay = &Calendar{
+		Location: newYork,
+		Workdays: Always.Workdays,
+		Start:    9 * time.Hour,
+		End:      17 * time.Hour,
+	}
+	return weekdays, everyDay
+}
+
+func TestElapsed(t *testing.T) {
+	weekdays, everyDay := testCalendars(t)
+	at := func(value string) time.Time {
+		return mustParse(t, weekdays.Location, value)
+	}
+
+	tests := []struct {
+		name     string
+		cal      *Calendar
+		from, to time.Time
+		want     time.Duration
+	}{
+		{"within a day", weekdays, at("2021-03-08 10:00"), at("2021-03-08 12:00"), 2 * time.Hour},
+		{"before and after hours", weekdays, at("2021-03-08 07:00"), at("2021-03-08 20:00"), 8 * time.Hour},
+		{"over a weekend", weekdays, at("2021-03-12 16:00"), at("2021-03-15 10:00"), 2 * time.Hour},
+		{"over a holiday weekend", weekdays, at("2021-07-02 16:00"), at("2021-07-06 10:00"), 2 * time.Hour},
+		{"a whole week", weekdays, at("2021-03-08 00:00"), at("2021-03-15 00:00"), 40 * time.Hour},
+		{"backwards", weekdays, at("2021-03-08 12:00"), at("2021-03-08 10:00"), 0},
+		{
+			"in UTC",
+			weekdays,
+			time.Date(2021, 3, 8, 14, 0, 0, 0, time.UTC),
+			time.Date(2021, 3, 8, 22, 0, 0, 0, time.UTC),
+			8 * time.Hour,
+		},
+		{
+			"in someone else's time zone",
+			weekdays.For("OctoCat"),
+			time.Date(2021, 3, 8, 9, 0, 0, 0, time.UTC),
+			time.Date(2021, 3, 8, 17, 0, 0, 0, time.UTC),
+			8 * time.Hour,
+		},
+		{"clocks go forward", everyDay, at("2021-03-14 09:00"), at("2021-03-14 10:00"), time.Hour},
+		{"clocks go back", everyDay, at("2021-11-07 09:00"), at("2021-11-07 10:00"), time.Hour},
+		{"every hour", Always, at("2021-03-13 12:00"), at("2021-03-15 12:00"), 47 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cal.Elapsed(tt.from, tt.to); got != tt.want {
+				t.Errorf("Elapsed(%s, %s) = %s, want %s", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdd(t *testing.T) {
+	weekdays, everyDay := testCalendars(t)
+	at := func(value string) time.Time {
+		return mustParse(t, weekdays.Location, value)
+	}
+
+	tests := []struct {
+		name string
+		cal  *Calendar
+		from time.Time
+		d    time.Duration
+		want time.Time
+	}{
+		{"within a day", weekdays, at("2021-03-08 10:00"), 2 * time.Hour, at("2021-03-08 12:00")},
+		{"before hours", weekdays, at("2021-03-08 07:00"), time.Hour, at("2021-03-08 10:00")},
+		{"after hours", weekdays, at("2021-03-08 18:00"), time.Hour, at("2021-03-09 10:00")},
+		{"over a weekend", weekdays, at("2021-03-12 16:00"), 2 * time.Hour, at("2021-03-15 10:00")},
+		{"over a holiday weekend", weekdays, at("2021-07-02 16:00"), 2 * time.Hour, at("2021-07-06 10:00")},
+		{"nothing", weekdays, at("2021-03-13 12:00"), 0, at("2021-03-13 12:00")},
+		{"clocks go forward", everyDay, at("2021-03-14 09:00"), time.Hour, at("2021-03-14 10:00")},
+		{"clocks go back", everyDay, at("2021-11-07 16:30"), time.Hour, at("2021-11-08 09:30")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cal.Add(tt.from, tt.d); !got.Equal(tt.want) {
+				t.Errorf("Add(%s, %s) = %s, want %s", tt.from, tt.d, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	weekdays, _ := testCalendars(t)
+
+	tests := []struct {
+		cal  *Calendar
+		d    time.Duration
+		want string
+	}{
+		{weekdays, 30 * time.Minute, "30m"},
+		{weekdays, 3 * time.Hour, "3h"},
+		{weekdays, 8 * time.Hour, "1d"},
+		// a day is a working day
+		{weekdays, 20 * time.Hour, "2d"},
+		{Always, 20 * time.Hour, "20h"},
+		{Always, 50 * time.Hour, "2d"},
+	}
+	for _, tt := range tests {
+		if got := tt.cal.Format(tt.d); got != tt.want {
+			t.Errorf("Format(%s) with %s days = %q, want %q", tt.d, tt.cal.Day(), got, tt.want)
+		}
+	}
+}
+
+func mustParse(t *testing.T, loc *time.Location, value string) time.Time {
+	t.Helper()
+	parsed, err := time.ParseInLocation("2006-01-02 15:04", value, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
@@ -0,0 +1,586 @@
+// package config - teamboard's settings, from flags, environment
+// variables and the $HOME/.teamboard.yaml config file.
+
+package config
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/StevenACoffman/teamboard/pkg/cache"
+	"github.com/StevenACoffman/teamboard/pkg/calendar"
+	"github.com/StevenACoffman/teamboard/pkg/github"
+	"github.com/StevenACoffman/teamboard/pkg/logging"
+	"github.com/StevenACoffman/teamboard/pkg/middleware"
+	"github.com/StevenACoffman/teamboard/pkg/types"
+)
+
+// DefaultAddr is where the board is served, if neither the config
+// nor $PORT say otherwise.
+const DefaultAddr = ":3000"
+
+// DefaultRateLimitReserve and DefaultRateLimitWait keep a little of the
+// budget back, and only wait about as long as a page can take.
+const (
+	DefaultRateLimitReserve = 100
+	DefaultRateLimitWait    = 5 * time.Second
+)
+
+// DefaultRetries and DefaultRetryMaxElapsed retry a few times, but leave
+// time for the other requests a page makes. Those all share the page's
+// server.RequestTimeout too, which retrying stops at, whatever the
+// RetryMaxElapsed, so a page is still served.
+const (
+	DefaultRetries         = 3
+	DefaultRetryMaxElapsed = 5 * time.Second
+)
+
+// Config is every setting teamboard has. Each one can come from, in order
+// of precedence: a command line flag, a TEAMBOARD_ environment variable
+// (e.g. TEAMBOARD_MAX_PAGES), the config file, or the default.
+type Config struct {
+	// Token is the GitHub token. It can also come from $GITHUB_TOKEN.
+	Token string `mapstructure:"token"`
+	// Org is the org to show when the URL doesn't pick one.
+	Org string `mapstructure:"org"`
+	// Orgs are the orgs to show together, on one board, when the URL
+	// doesn't pick any. It takes the place of Org.
+	Orgs []string `mapstructure:"orgs"`
+	// Team is the team to show when the URL doesn't pick one.
+	Team string `mapstructure:"team"`
+	// Login is who the board is for, in place of whoever the token
+	// belongs to. A GitHub App has no one signed in, so without a Login
+	// its boards only show what the team is involved in.
+	Login string `mapstructure:"login"`
+	// Addr is the address to listen on, like :3000
+	Addr string `mapstructure:"addr"`
+	// MaxPages is the most pages of 100 results to fetch for each search.
+	MaxPages int `mapstructure:"max-pages"`
+	// ChildTeams counts the members of child teams as teammates too.
+	ChildTeams bool `mapstructure:"child-teams"`
+	// Drafts shows the draft pull requests the team wrote, or that are in
+	// Repos or found by Queries, too.
+	Drafts bool `mapstructure:"drafts"`
+	// RateLimitReserve is how many points of GitHub's hourly API budget
+	// to leave alone. Once there are only that many left, requests wait
+	// for the budget to reset, or fail if that's too long.
+	RateLimitReserve int `mapstructure:"rate-limit-reserve"`
+	// RateLimitWait is the longest to wait for the budget to reset.
+	RateLimitWait time.Duration `mapstructure:"rate-limit-wait"`
+	// Retries is how many times to retry a request that failed in a way
+	// that might work next time. Zero turns retrying off.
+	Retries int `mapstructure:"retries"`
+	// RetryMaxElapsed is the longest to spend retrying a request.
+	// Zero is no limit.
+	RetryMaxElapsed time.Duration `mapstructure:"retry-max-elapsed"`
+	// NoRedact logs the token, cookies and token-like fields of failed
+	// requests, instead of hiding them. It's only for debugging locally.
+	NoRedact bool `mapstructure:"no-redact"`
+	// LogFormat is how logs are written: logfmt or json.
+	LogFormat string `mapstructure:"log-format"`
+	// LogLevel is the least important level that is logged: debug, info,
+	// warn or error.
+	LogLevel string `mapstructure:"log-level"`
+	// CacheTTLs override how long each GraphQL operation is cached,
+	// e.g. MyBatch: 30s. Zero turns caching off for the operation.
+	CacheTTLs map[string]time.Duration `mapstructure:"cache-ttls"`
+	// Repos are extra repositories, like Khan/webapp, whose open pull
+	// requests are shown on the board, whoever wrote them.
+	Repos []string `mapstructure:"repos"`
+	// Queries are custom searches, each shown as its own section
+	// of the board.
+	Queries []github.Query `mapstructure:"queries"`
+	// SLAs are how long a pull request can wait, for each reason it is on
+	// the board (including custom query names), before it is overdue,
+	// e.g. review-requested: 24h
+	SLAs map[string]time.Duration `mapstructure:"slas"`
+	// Calendar is when people work. With one, ages and SLAs only count
+	// working hours, so an SLA of 8h is one 9 to 5 working day.
+	Calendar Calendar `mapstructure:"calendar"`
+	// Boards are saved boards, each served at /board/<name>
+	Boards []Board `mapstructure:"boards"`
+	// App authenticates as a GitHub App, instead of with Token.
+	App App `mapstructure:"app"`
+
+	// calendar is Calendar, checked and ready to use
+	calendar *calendar.Calendar
+	// appKey is the App's private key, parsed
+	appKey *rsa.PrivateKey
+}
+
+// App is a GitHub App to authenticate as, for a shared deployment that
+// shouldn't depend on anyone's personal token. The app needs read access
+// to pull requests, and to members of the org, and has to be installed
+// in every org shown.
+type App struct {
+	// ID is the app's ID, from its settings page.
+	ID int64 `mapstructure:"id"`
+	// PrivateKey is one of the app's private keys, PEM encoded.
+	PrivateKey string `mapstructure:"private-key"`
+	// PrivateKeyFile is where the private key is, instead of PrivateKey.
+	PrivateKeyFile string `mapstructure:"private-key-file"`
+}
+
+// enabled reports whether there is an app to authenticate as.
+func (a App) enabled() bool {
+	return a.ID != 0 || a.PrivateKey != "" || a.PrivateKeyFile != ""
+}
+
+// key reads and parses the app's private key.
+func (a App) key() (*rsa.PrivateKey, error) {
+	if a.ID <= 0 {
+		return nil, fmt.Errorf("app needs an id")
+	}
+	data := []byte(a.PrivateKey)
+	switch {
+	case a.PrivateKey != "" && a.PrivateKeyFile != "":
+		return nil, fmt.Errorf("app needs a private-key or a private-key-file, not both")
+	case a.PrivateKeyFile != "":
+		var err error
+		data, err = ioutil.ReadFile(a.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("app private-key-file: %w", err)
+		}
+	case a.PrivateKey == "":
+		return nil, fmt.Errorf("app %d needs a private-key or a private-key-file", a.ID)
+	}
+	return middleware.ParsePrivateKey(data)
+}
+
+// Calendar is the working week. Any of it that is left out takes
+// the default: Monday to Friday, 09:00-17:00, local time.
+type Calendar struct {
+	// TimeZone is the time zone of anyone not in TimeZones,
+	// like America/New_York
+	TimeZone string `mapstructure:"time-zone"`
+	// Workdays are the days of the week people work, like Mon
+	Workdays []string `mapstructure:"workdays"`
+	// Hours are the working hours, like 09:00-17:00
+	Hours string `mapstructure:"hours"`
+	// Holidays are the days nobody works, like 2021-12-25
+	Holidays []string `mapstructure:"holidays"`
+	// TimeZones are the time zones of particular people, by login.
+	TimeZones map[string]string `mapstructure:"time-zones"`
+}
+
+// empty reports whether there is no calendar at all.
+func (c Calendar) empty() bool {
+	return c.TimeZone == "" && len(c.Workdays) == 0 && c.Hours == "" &&
+		len(c.Holidays) == 0 && len(c.TimeZones) == 0
+}
+
+// build checks the calendar, and makes a calendar.Calendar of it.
+func (c Calendar) build() (*calendar.Calendar, error) {
+	cal := &calendar.Calendar{
+		Location: time.Local,
+		Workdays: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		Start:    9 * time.Hour,
+		End:      17 * time.Hour,
+		Zones:    make(map[string]*time.Location, len(c.TimeZones)),
+	}
+
+	if c.TimeZone != "" {
+		loc, err := time.LoadLocation(c.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("calendar time-zone: %w", err)
+		}
+		cal.Location = loc
+	}
+	for login, zone := range c.TimeZones {
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			return nil, fmt.Errorf("calendar time-zones %s: %w", login, err)
+		}
+		cal.Zones[login] = loc
+	}
+
+	if len(c.Workdays) > 0 {
+		cal.Workdays = nil
+		for _, name := range c.Workdays {
+			weekday, ok := parseWeekday(name)
+			if !ok {
+				return nil, fmt.Errorf("calendar workday %q isn't a day of the week", name)
+			}
+			cal.Workdays = append(cal.Workdays, weekday)
+		}
+	}
+
+	if c.Hours != "" {
+		parts := strings.Split(c.Hours, "-")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("calendar hours must look like 09:00-17:00, not %q", c.Hours)
+		}
+		start, err := time.Parse("15:04", strings.TrimSpace(parts[0]))
+		if err != nil {
+			return nil, fmt.Errorf("calendar hours: %w", err)
+		}
+		end, err := time.Parse("15:04", strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("calendar hours: %w", err)
+		}
+		cal.Start = time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute
+		cal.End = time.Duration(end.Hour())*time.Hour + time.Duration(end.Minute())*time.Minute
+		if cal.End <= cal.Start {
+			return nil, fmt.Errorf("calendar hours %q must end after they start", c.Hours)
+		}
+	}
+
+	for _, holiday := range c.Holidays {
+		if _, err := time.Parse("2006-01-02", holiday); err != nil {
+			return nil, fmt.Errorf("calendar holidays must look like 2021-12-25, not %q", holiday)
+		}
+		cal.Holidays = append(cal.Holidays, holiday)
+	}
+	return cal, nil
+}
+
+// parseWeekday is the day of the week called name, like Monday or Mon
+func parseWeekday(name string) (time.Weekday, bool) {
+	name = strings.ToLower(name)
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		full := strings.ToLower(day.String())
+		if name == full || name == full[:3] {
+			return day, true
+		}
+	}
+	return 0, false
+}
+
+// Board is a saved board, for an org and team along with any extras.
+type Board struct {
+	// Name is how the board is found, at /board/<name>
+	Name string `mapstructure:"name"`
+	Org  string `mapstructure:"org"`
+	Team string `mapstructure:"team"`
+	// Users are extra people whose pull requests count as the team's.
+	Users []string `mapstructure:"users"`
+	// Repos are extra repositories whose open pull requests are shown.
+	Repos []string `mapstructure:"repos"`
+	// Qualifiers are added to every search for the board,
+	// e.g. -label:wip
+	Qualifiers string `mapstructure:"qualifiers"`
+	// Queries are custom searches, each shown as its own section.
+	Queries []github.Query `mapstructure:"queries"`
+}
+
+// DefaultOrgs are the orgs to show when the URL doesn't pick any:
+// Orgs, or else Org.
+func (c Config) DefaultOrgs() []string {
+	if len(c.Orgs) > 0 {
+		return c.Orgs
+	}
+	if c.Org != "" {
+		return []string{c.Org}
+	}
+	return nil
+}
+
+// AppOrgs are the orgs a GitHub App offers to pick from, since it has no
+// one signed in to belong to any: the DefaultOrgs, then those of the
+// saved Boards.
+func (c Config) AppOrgs() []string {
+	var orgs []string
+	seen := make(map[string]bool)
+	for _, org := range c.DefaultOrgs() {
+		if !seen[strings.ToLower(org)] {
+			seen[strings.ToLower(org)] = true
+			orgs = append(orgs, org)
+		}
+	}
+	for _, b := range c.Boards {
+		if !seen[strings.ToLower(b.Org)] {
+			seen[strings.ToLower(b.Org)] = true
+			orgs = append(orgs, b.Org)
+		}
+	}
+	return orgs
+}
+
+// FixedLogin is who boards are for, when it isn't up to the token: Login,
+// if it's set, or else no one for a GitHub App.
+func (c Config) FixedLogin() (login string, ok bool) {
+	if c.Login != "" {
+		return c.Login, true
+	}
+	return "", c.appKey != nil
+}
+
+// Board returns the saved board called name.
+func (c Config) Board(name string) (Board, bool) {
+	for _, b := range c.Boards {
+		if b.Name == name {
+			return b, true
+		}
+	}
+	return Board{}, false
+}
+
+// SetDefaults registers every key with v, along with its default, so that
+// v picks up environment variables for all of them.
+func SetDefaults(v *viper.Viper) {
+	addr := DefaultAddr
+	// $PORT was how the port was set before there was a config
+	if port := os.Getenv("PORT"); port != "" {
+		addr = ":" + port
+	}
+
+	v.SetDefault("token", "")
+	v.SetDefault("org", "")
+	v.SetDefault("orgs", []string{})
+	v.SetDefault("team", "")
+	v.SetDefault("login", "")
+	v.SetDefault("addr", addr)
+	v.SetDefault("max-pages", github.DefaultMaxPages)
+	v.SetDefault("child-teams", false)
+	v.SetDefault("drafts", false)
+	v.SetDefault("rate-limit-reserve", DefaultRateLimitReserve)
+	v.SetDefault("rate-limit-wait", DefaultRateLimitWait)
+	v.SetDefault("retries", DefaultRetries)
+	v.SetDefault("retry-max-elapsed", DefaultRetryMaxElapsed)
+	v.SetDefault("log-format", string(logging.FormatLogfmt))
+	v.SetDefault("log-level", logging.LevelInfo.String())
+	v.SetDefault("cache-ttls", map[string]time.Duration{})
+	v.SetDefault("repos", []string{})
+	v.SetDefault("queries", []github.Query{})
+	v.SetDefault("slas", map[string]time.Duration{})
+	v.SetDefault("boards", []Board{})
+
+	v.SetEnvPrefix("teamboard")
+	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	v.AutomaticEnv()
+	_ = v.BindEnv("token", "TEAMBOARD_TOKEN", "GITHUB_TOKEN")
+	_ = v.BindEnv("app.id", "TEAMBOARD_APP_ID")
+	_ = v.BindEnv("app.private-key", "TEAMBOARD_APP_PRIVATE_KEY")
+	_ = v.BindEnv("app.private-key-file", "TEAMBOARD_APP_PRIVATE_KEY_FILE")
+}
+
+// Load reads the Config out of v.
+func Load(v *viper.Viper) (Config, error) {
+	var c Config
+	if err := v.Unmarshal(&c); err != nil {
+		return c, fmt.Errorf("unable to read config: %w", err)
+	}
+	if c.Team != "" && c.Org == "" && len(c.Orgs) == 0 {
+		return c, fmt.Errorf("a default team (%s) needs a default org too", c.Team)
+	}
+	if _, err := logging.ParseFormat(c.LogFormat); err != nil {
+		return c, err
+	}
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		return c, err
+	}
+	if err := checkRepos(c.Repos); err != nil {
+		return c, err
+	}
+	if err := checkQueries(c.Queries); err != nil {
+		return c, err
+	}
+	if err := c.checkSLAs(); err != nil {
+		return c, err
+	}
+	if c.App.enabled() {
+		key, err := c.App.key()
+		if err != nil {
+			return c, err
+		}
+		if len(c.DefaultOrgs()) == 0 {
+			// the installation used for requests that aren't about any org
+			return c, fmt.Errorf("app %d needs a default org or orgs too", c.App.ID)
+		}
+		c.appKey = key
+	}
+	if !c.Calendar.empty() {
+		cal, err := c.Calendar.build()
+		if err != nil {
+			return c, err
+		}
+		c.calendar = cal
+	}
+
+	names := make(map[string]bool)
+	for i, b := range c.Boards {
+		switch {
+		case b.Name == "":
+			return c, fmt.Errorf("board %d needs a name", i+1)
+		case strings.Contains(b.Name, "/"):
+			return c, fmt.Errorf("board name %q can't contain /", b.Name)
+		case names[b.Name]:
+			return c, fmt.Errorf("there is more than one board called %q", b.Name)
+		case b.Org == "" || b.Team == "":
+			return c, fmt.Errorf("board %q needs both an org and a team", b.Name)
+		}
+		names[b.Name] = true
+		if err := checkRepos(b.Repos); err != nil {
+			return c, fmt.Errorf("board %q: %w", b.Name, err)
+		}
+		if err := checkQueries(b.Queries); err != nil {
+			return c, fmt.Errorf("board %q: %w", b.Name, err)
+		}
+	}
+	return c, nil
+}
+
+func checkQueries(queries []github.Query) error {
+	names := make(map[string]bool)
+	for _, reason := range types.Reasons {
+		names[string(reason)] = true
+	}
+	for i, q := range queries {
+		switch {
+		case q.Name == "":
+			return fmt.Errorf("query %d needs a name", i+1)
+		case strings.ContainsAny(q.Name, " \t\"'<>&"):
+			return fmt.Errorf("query name %q can't contain spaces, quotes or HTML", q.Name)
+		case names[q.Name]:
+			return fmt.Errorf("query name %q is already taken", q.Name)
+		case strings.TrimSpace(q.Query) == "":
+			return fmt.Errorf("query %q needs a query", q.Name)
+		}
+		names[q.Name] = true
+	}
+	return nil
+}
+
+// checkSLAs makes sure every SLA is for a reason that exists, either
+// built in, or the name of a custom query on some board.
+func (c Config) checkSLAs() error {
+	for name, sla := range c.SLAs {
+		if sla < 0 {
+			return fmt.Errorf("the SLA for %s can't be negative", name)
+		}
+		if _, ok := findReason(name, c.allQueries()); !ok {
+			return fmt.Errorf("there is no reason or query called %q to have an SLA", name)
+		}
+	}
+	return nil
+}
+
+// allQueries are the top level queries, and those of every board.
+func (c Config) allQueries() []github.Query {
+	queries := c.Queries
+	for _, b := range c.Boards {
+		queries = append(queries[:len(queries):len(queries)], b.Queries...)
+	}
+	return queries
+}
+
+// findReason is the reason called name, matched case insensitively since
+// viper lower cases keys, among the built in ones and queries.
+func findReason(name string, queries []github.Query) (types.Reason, bool) {
+	for _, reason := range types.Reasons {
+		if strings.EqualFold(string(reason), name) {
+			return reason, true
+		}
+	}
+	for _, q := range queries {
+		if strings.EqualFold(q.Name, name) {
+			return types.Reason(q.Name), true
+		}
+	}
+	return "", false
+}
+
+// slas are the SLAs that apply to a board with queries.
+func (c Config) slas(queries []github.Query) map[types.Reason]time.Duration {
+	slas := make(map[types.Reason]time.Duration, len(c.SLAs))
+	for name, sla := range c.SLAs {
+		if reason, ok := findReason(name, queries); ok {
+			slas[reason] = sla
+		}
+	}
+	return slas
+}
+
+func checkRepos(repos []string) error {
+	for _, repo := range repos {
+		if !strings.Contains(repo, "/") {
+			return fmt.Errorf("repos must look like owner/name, not %q", repo)
+		}
+	}
+	return nil
+}
+
+// SearchOptions are the options for github.GetPulls.
+func (c Config) SearchOptions() github.SearchOptions {
+	return github.SearchOptions{
+		MaxPages:   c.MaxPages,
+		ChildTeams: c.ChildTeams,
+		Drafts:     c.Drafts,
+		Repos:      c.Repos,
+		Queries:    c.Queries,
+		SLAs:       c.slas(c.Queries),
+		Calendar:   c.calendar,
+	}
+}
+
+// BoardSearchOptions are the options for github.GetPulls for a saved
+// board. The board's extras take the place of the top level Repos.
+func (c Config) BoardSearchOptions(b Board) github.SearchOptions {
+	opts := c.SearchOptions()
+	opts.Repos = b.Repos
+	opts.Users = b.Users
+	opts.Qualifiers = b.Qualifiers
+	opts.Queries = b.Queries
+	opts.SLAs = c.slas(b.Queries)
+	return opts
+}
+
+// ClientOptions are the options for middleware.NewGitHubHTTPClient
+func (c Config) ClientOptions() middleware.ClientOptions {
+	return middleware.ClientOptions{
+		RateLimitReserve: c.RateLimitReserve,
+		RateLimitWait:    c.RateLimitWait,
+		Retries:          c.Retries,
+		RetryMaxElapsed:  c.RetryMaxElapsed,
+		NoRedact:         c.NoRedact,
+		App:              c.AppAuth(),
+	}
+}
+
+// AppAuth is how to authenticate as the App, or nil if there isn't one.
+// Requests that aren't about any org in particular use the installation
+// in the first default org.
+func (c Config) AppAuth() *middleware.AppAuth {
+	if c.appKey == nil {
+		return nil
+	}
+	auth := &middleware.AppAuth{AppID: c.App.ID, PrivateKey: c.appKey}
+	if orgs := c.DefaultOrgs(); len(orgs) > 0 {
+		auth.DefaultOrg = orgs[0]
+	}
+	return auth
+}
+
+// Logger is a logger writing to w in LogFormat, from LogLevel up.
+func (c Config) Logger(w io.Writer) *logging.Logger {
+	format, _ := logging.ParseFormat(c.LogFormat)
+	level, _ := logging.ParseLevel(c.LogLevel)
+	return logging.New(w, format, level)
+}
+
+// TTLs are cache.DefaultTTLs with CacheTTLs laid over them. Viper lower
+// cases keys, so operation names are matched case insensitively.
+func (c Config) TTLs() map[string]time.Duration {
+	ttls := make(map[string]time.Duration, len(cache.DefaultTTLs))
+	for op, ttl := range cache.DefaultTTLs {
+		ttls[op] = ttl
+	}
+	for name, ttl := range c.CacheTTLs {
+		op := name
+		for known := range cache.DefaultTTLs {
+			if strings.EqualFold(known, name) {
+				op = known
+				break
+			}
+		}
+		ttls[op] = ttl
+	}
+	return ttls
+}
@@ -9,73 +9,59 @@ import (
 	"github.com/StevenACoffman/teamboard/pkg/types"
 )
 
-// MyBatchMementionedSearchResultItemConnection includes the requested fields of the GraphQL type SearchResultItemConnection.
+// ChildTeamsOrganization includes the requested fields of the GraphQL type Organization.
 // The GraphQL type's documentation follows.
 //
-// A list of results that matched against a search query.
-type MyBatchMementionedSearchResultItemConnection struct {
-	// The number of issues that matched the search query.
-	IssueCount int `json:"issueCount"`
-	// A list of edges.
-	Edges []types.Edge `json:"edges"`
+// An account on GitHub, with one or more owners, that has repositories, members and teams.
+type ChildTeamsOrganization struct {
+	// Find an organization's team by its slug.
+	Team *ChildTeamsOrganizationTeam `json:"team"`
 }
 
-// MyBatchMerequestedSearchResultItemConnection includes the requested fields of the GraphQL type SearchResultItemConnection.
+// ChildTeamsOrganizationTeam includes the requested fields of the GraphQL type Team.
 // The GraphQL type's documentation follows.
 //
-// A list of results that matched against a search query.
-type MyBatchMerequestedSearchResultItemConnection struct {
-	// The number of issues that matched the search query.
-	IssueCount int `json:"issueCount"`
-	// A list of edges.
-	Edges []types.Edge `json:"edges"`
-}
-
-// MyBatchResponse is returned by MyBatch on success.
-type MyBatchResponse struct {
-	// Perform a search across resources.
-	Merequested MyBatchMerequestedSearchResultItemConnection `json:"merequested"`
-	// Perform a search across resources.
-	Mementioned MyBatchMementionedSearchResultItemConnection `json:"mementioned"`
-	// Perform a search across resources.
-	Teammates MyBatchTeammatesSearchResultItemConnection `json:"teammates"`
-	// Perform a search across resources.
-	Teammentions MyBatchTeammentionsSearchResultItemConnection `json:"teammentions"`
-	// Perform a search across resources.
-	Teamrequested MyBatchTeamrequestedSearchResultItemConnection `json:"teamrequested"`
+// A team of users in an organization.
+type ChildTeamsOrganizationTeam struct {
+	// List of child teams belonging to this team
+	ChildTeams ChildTeamsOrganizationTeamChildTeamsTeamConnection `json:"childTeams"`
 }
 
-// MyBatchTeammatesSearchResultItemConnection includes the requested fields of the GraphQL type SearchResultItemConnection.
+// ChildTeamsOrganizationTeamChildTeamsTeamConnection includes the requested fields of the GraphQL type TeamConnection.
 // The GraphQL type's documentation follows.
 //
-// A list of results that matched against a search query.
-type MyBatchTeammatesSearchResultItemConnection struct {
-	// The number of issues that matched the search query.
-	IssueCount int `json:"issueCount"`
-	// A list of edges.
-	Edges []types.Edge `json:"edges"`
+// The connection type for Team.
+type ChildTeamsOrganizationTeamChildTeamsTeamConnection struct {
+	// Information to aid in pagination.
+	PageInfo ChildTeamsOrganizationTeamChildTeamsTeamConnectionPageInfo `json:"pageInfo"`
+	// A list of nodes.
+	Nodes []ChildTeamsOrganizationTeamChildTeamsTeamConnectionNodesTeam `json:"nodes"`
 }
 
-// MyBatchTeammentionsSearchResultItemConnection includes the requested fields of the GraphQL type SearchResultItemConnection.
+// ChildTeamsOrganizationTeamChildTeamsTeamConnectionNodesTeam includes the requested fields of the GraphQL type Team.
 // The GraphQL type's documentation follows.
 //
-// A list of results that matched against a search query.
-type MyBatchTeammentionsSearchResultItemConnection struct {
-	// The number of issues that matched the search query.
-	IssueCount int `json:"issueCount"`
-	// A list of edges.
-	Edges []types.Edge `json:"edges"`
+// A team of users in an organization.
+type ChildTeamsOrganizationTeamChildTeamsTeamConnectionNodesTeam struct {
+	// The slug corresponding to the team.
+	Slug string `json:"slug"`
 }
 
-// MyBatchTeamrequestedSearchResultItemConnection includes the requested fields of the GraphQL type SearchResultItemConnection.
+// ChildTeamsOrganizationTeamChildTeamsTeamConnectionPageInfo includes the requested fields of the GraphQL type PageInfo.
 // The GraphQL type's documentation follows.
 //
-// A list of results that matched against a search query.
-type MyBatchTeamrequestedSearchResultItemConnection struct {
-	// The number of issues that matched the search query.
-	IssueCount int `json:"issueCount"`
-	// A list of edges.
-	Edges []types.Edge `json:"edges"`
+// Information about pagination in a connection.
+type ChildTeamsOrganizationTeamChildTeamsTeamConnectionPageInfo struct {
+	// When paginating forwards, are there more items?
+	HasNextPage bool `json:"hasNextPage"`
+	// When paginating forwards, the cursor to continue.
+	EndCursor string `json:"endCursor"`
+}
+
+// ChildTeamsResponse is returned by ChildTeams on success.
+type ChildTeamsResponse struct {
+	// Lookup a organization by login.
+	Organization ChildTeamsOrganization `json:"organization"`
 }
 
 // MyLoginResponse is returned by MyLogin on success.
@@ -113,6 +99,8 @@ type MyOrgsViewerUser struct {
 //
 // The connection type for Organization.
 type MyOrgsViewerUserOrganizationsOrganizationConnection struct {
+	// Information to aid in pagination.
+	PageInfo MyOrgsViewerUserOrganizationsOrganizationConnectionPageInfo `json:"pageInfo"`
 	// A list of nodes.
 	Nodes []MyOrgsViewerUserOrganizationsOrganizationConnectionNodesOrganization `json:"nodes"`
 }
@@ -126,6 +114,17 @@ type MyOrgsViewerUserOrganizationsOrganizationConnectionNodesOrganization struct
 	Login string `json:"login"`
 }
 
+// MyOrgsViewerUserOrganizationsOrganizationConnectionPageInfo includes the requested fields of the GraphQL type PageInfo.
+// The GraphQL type's documentation follows.
+//
+// Information about pagination in a connection.
+type MyOrgsViewerUserOrganizationsOrganizationConnectionPageInfo struct {
+	// When paginating forwards, are there more items?
+	HasNextPage bool `json:"hasNextPage"`
+	// When paginating forwards, the cursor to continue.
+	EndCursor string `json:"endCursor"`
+}
+
 // MyTeamsOrganization includes the requested fields of the GraphQL type Organization.
 // The GraphQL type's documentation follows.
 //
@@ -142,6 +141,8 @@ type MyTeamsOrganization struct {
 type MyTeamsOrganizationTeamsTeamConnection struct {
 	// Identifies the total count of items in the connection.
 	TotalCount int `json:"totalCount"`
+	// Information to aid in pagination.
+	PageInfo MyTeamsOrganizationTeamsTeamConnectionPageInfo `json:"pageInfo"`
 	// A list of edges.
 	Edges []MyTeamsOrganizationTeamsTeamConnectionEdgesTeamEdge `json:"edges"`
 }
@@ -162,92 +163,140 @@ type MyTeamsOrganizationTeamsTeamConnectionEdgesTeamEdge struct {
 type MyTeamsOrganizationTeamsTeamConnectionEdgesTeamEdgeNodeTeam struct {
 	// The name of the team.
 	Name string `json:"name"`
+	// The slug corresponding to the team.
+	Slug string `json:"slug"`
 	// The description of the team.
 	Description string `json:"description"`
 }
 
+// MyTeamsOrganizationTeamsTeamConnectionPageInfo includes the requested fields of the GraphQL type PageInfo.
+// The GraphQL type's documentation follows.
+//
+// Information about pagination in a connection.
+type MyTeamsOrganizationTeamsTeamConnectionPageInfo struct {
+	// When paginating forwards, are there more items?
+	HasNextPage bool `json:"hasNextPage"`
+	// When paginating forwards, the cursor to continue.
+	EndCursor string `json:"endCursor"`
+}
+
 // MyTeamsResponse is returned by MyTeams on success.
 type MyTeamsResponse struct {
 	// Lookup a organization by login.
 	Organization MyTeamsOrganization `json:"organization"`
 }
 
-// TeamMembersOrganization includes the requested fields of the GraphQL type Organization.
+// SearchPullsResponse is returned by SearchPulls on success.
+type SearchPullsResponse struct {
+	// Perform a search across resources.
+	Search SearchPullsSearchSearchResultItemConnection `json:"search"`
+}
+
+// SearchPullsSearchSearchResultItemConnection includes the requested fields of the GraphQL type SearchResultItemConnection.
 // The GraphQL type's documentation follows.
 //
-// An account on GitHub, with one or more owners, that has repositories, members and teams.
-type TeamMembersOrganization struct {
-	// A list of teams in this organization.
-	Teams TeamMembersOrganizationTeamsTeamConnection `json:"teams"`
+// A list of results that matched against a search query.
+type SearchPullsSearchSearchResultItemConnection struct {
+	// The number of issues that matched the search query.
+	IssueCount int `json:"issueCount"`
+	// Information to aid in pagination.
+	PageInfo SearchPullsSearchSearchResultItemConnectionPageInfo `json:"pageInfo"`
+	// A list of edges.
+	Edges []types.Edge `json:"edges"`
 }
 
-// TeamMembersOrganizationTeamsTeamConnection includes the requested fields of the GraphQL type TeamConnection.
+// SearchPullsSearchSearchResultItemConnectionPageInfo includes the requested fields of the GraphQL type PageInfo.
 // The GraphQL type's documentation follows.
 //
-// The connection type for Team.
-type TeamMembersOrganizationTeamsTeamConnection struct {
-	// Identifies the total count of items in the connection.
-	TotalCount int `json:"totalCount"`
-	// A list of edges.
-	Edges []TeamMembersOrganizationTeamsTeamConnectionEdgesTeamEdge `json:"edges"`
+// Information about pagination in a connection.
+type SearchPullsSearchSearchResultItemConnectionPageInfo struct {
+	// When paginating forwards, are there more items?
+	HasNextPage bool `json:"hasNextPage"`
+	// When paginating forwards, the cursor to continue.
+	EndCursor string `json:"endCursor"`
 }
 
-// TeamMembersOrganizationTeamsTeamConnectionEdgesTeamEdge includes the requested fields of the GraphQL type TeamEdge.
+// TeamMembersOrganization includes the requested fields of the GraphQL type Organization.
 // The GraphQL type's documentation follows.
 //
-// An edge in a connection.
-type TeamMembersOrganizationTeamsTeamConnectionEdgesTeamEdge struct {
-	// The item at the end of the edge.
-	Node TeamMembersOrganizationTeamsTeamConnectionEdgesTeamEdgeNodeTeam `json:"node"`
+// An account on GitHub, with one or more owners, that has repositories, members and teams.
+type TeamMembersOrganization struct {
+	// Find an organization's team by its slug.
+	Team *TeamMembersOrganizationTeam `json:"team"`
 }
 
-// TeamMembersOrganizationTeamsTeamConnectionEdgesTeamEdgeNodeTeam includes the requested fields of the GraphQL type Team.
+// TeamMembersOrganizationTeam includes the requested fields of the GraphQL type Team.
 // The GraphQL type's documentation follows.
 //
 // A team of users in an organization.
-type TeamMembersOrganizationTeamsTeamConnectionEdgesTeamEdgeNodeTeam struct {
+type TeamMembersOrganizationTeam struct {
 	// A list of users who are members of this team.
-	Members TeamMembersOrganizationTeamsTeamConnectionEdgesTeamEdgeNodeTeamMembersTeamMemberConnection `json:"members"`
+	Members TeamMembersOrganizationTeamMembersTeamMemberConnection `json:"members"`
 	// The name of the team.
 	Name string `json:"name"`
 	// The description of the team.
 	Description string `json:"description"`
 }
 
-// TeamMembersOrganizationTeamsTeamConnectionEdgesTeamEdgeNodeTeamMembersTeamMemberConnection includes the requested fields of the GraphQL type TeamMemberConnection.
+// TeamMembersOrganizationTeamMembersTeamMemberConnection includes the requested fields of the GraphQL type TeamMemberConnection.
 // The GraphQL type's documentation follows.
 //
 // The connection type for User.
-type TeamMembersOrganizationTeamsTeamConnectionEdgesTeamEdgeNodeTeamMembersTeamMemberConnection struct {
+type TeamMembersOrganizationTeamMembersTeamMemberConnection struct {
+	// Information to aid in pagination.
+	PageInfo TeamMembersOrganizationTeamMembersTeamMemberConnectionPageInfo `json:"pageInfo"`
 	// A list of edges.
-	Edges []TeamMembersOrganizationTeamsTeamConnectionEdgesTeamEdgeNodeTeamMembersTeamMemberConnectionEdgesTeamMemberEdge `json:"edges"`
+	Edges []TeamMembersOrganizationTeamMembersTeamMemberConnectionEdgesTeamMemberEdge `json:"edges"`
 }
 
-// TeamMembersOrganizationTeamsTeamConnectionEdgesTeamEdgeNodeTeamMembersTeamMemberConnectionEdgesTeamMemberEdge includes the requested fields of the GraphQL type TeamMemberEdge.
+// TeamMembersOrganizationTeamMembersTeamMemberConnectionEdgesTeamMemberEdge includes the requested fields of the GraphQL type TeamMemberEdge.
 // The GraphQL type's documentation follows.
 //
 // Represents a user who is a member of a team.
-type TeamMembersOrganizationTeamsTeamConnectionEdgesTeamEdgeNodeTeamMembersTeamMemberConnectionEdgesTeamMemberEdge struct {
-	Node TeamMembersOrganizationTeamsTeamConnectionEdgesTeamEdgeNodeTeamMembersTeamMemberConnectionEdgesTeamMemberEdgeNodeUser `json:"node"`
+type TeamMembersOrganizationTeamMembersTeamMemberConnectionEdgesTeamMemberEdge struct {
+	Node TeamMembersOrganizationTeamMembersTeamMemberConnectionEdgesTeamMemberEdgeNodeUser `json:"node"`
 }
 
-// TeamMembersOrganizationTeamsTeamConnectionEdgesTeamEdgeNodeTeamMembersTeamMemberConnectionEdgesTeamMemberEdgeNodeUser includes the requested fields of the GraphQL type User.
+// TeamMembersOrganizationTeamMembersTeamMemberConnectionEdgesTeamMemberEdgeNodeUser includes the requested fields of the GraphQL type User.
 // The GraphQL type's documentation follows.
 //
 // A user is an individual's account on GitHub that owns repositories and can make new content.
-type TeamMembersOrganizationTeamsTeamConnectionEdgesTeamEdgeNodeTeamMembersTeamMemberConnectionEdgesTeamMemberEdgeNodeUser struct {
+type TeamMembersOrganizationTeamMembersTeamMemberConnectionEdgesTeamMemberEdgeNodeUser struct {
 	// The user's public profile name.
 	Name string `json:"name"`
 	// The username used to login.
 	Login string `json:"login"`
 }
 
+// TeamMembersOrganizationTeamMembersTeamMemberConnectionPageInfo includes the requested fields of the GraphQL type PageInfo.
+// The GraphQL type's documentation follows.
+//
+// Information about pagination in a connection.
+type TeamMembersOrganizationTeamMembersTeamMemberConnectionPageInfo struct {
+	// When paginating forwards, are there more items?
+	HasNextPage bool `json:"hasNextPage"`
+	// When paginating forwards, the cursor to continue.
+	EndCursor string `json:"endCursor"`
+}
+
 // TeamMembersResponse is returned by TeamMembers on success.
 type TeamMembersResponse struct {
 	// Lookup a organization by login.
 	Organization TeamMembersOrganization `json:"organization"`
 }
 
+// Defines which types of team members are included in the returned list. Can be one of IMMEDIATE, CHILD_TEAM or ALL.
+type TeamMembershipType string
+
+const (
+	// Includes immediate and child team members for the team.
+	TeamMembershipTypeAll TeamMembershipType = "ALL"
+	// Includes only child team members for the team.
+	TeamMembershipTypeChildTeam TeamMembershipType = "CHILD_TEAM"
+	// Includes only immediate members of the team.
+	TeamMembershipTypeImmediate TeamMembershipType = "IMMEDIATE"
+)
+
 func MyLogin(
 	ctx context.Context,
 	client graphql.Client,
@@ -272,15 +321,27 @@ query MyLogin {
 func MyOrgs(
 	ctx context.Context,
 	client graphql.Client,
+	after string,
 ) (*MyOrgsResponse, error) {
+	variables := map[string]interface{}{}
+
+	var zero_after string
+	if after != zero_after {
+		variables["After"] = after
+	}
+
 	var retval MyOrgsResponse
 	err := client.MakeRequest(
 		ctx,
 		"MyOrgs",
 		`
-query MyOrgs {
+query MyOrgs ($After: String) {
 	viewer {
-		organizations(first: 100) {
+		organizations(first: 100, after: $After) {
+			pageInfo {
+				hasNextPage
+				endCursor
+			}
 			nodes {
 				login
 			}
@@ -289,20 +350,31 @@ query MyOrgs {
 }
 `,
 		&retval,
-		nil,
+		variables,
 	)
 	return &retval, err
 }
 
+// MyTeams lists the teams in $Org that any of $Logins are in, or every
+// team without any.
 func MyTeams(
 	ctx context.Context,
 	client graphql.Client,
 	org string,
-	login string,
+	logins []string,
+	after string,
 ) (*MyTeamsResponse, error) {
 	variables := map[string]interface{}{
-		"Org":   org,
-		"Login": login,
+		"Org": org,
+	}
+
+	if len(logins) > 0 {
+		variables["Logins"] = logins
+	}
+
+	var zero_after string
+	if after != zero_after {
+		variables["After"] = after
 	}
 
 	var retval MyTeamsResponse
@@ -310,13 +382,18 @@ func MyTeams(
 		ctx,
 		"MyTeams",
 		`
-query MyTeams ($Org: String!, $Login: String!) {
+query MyTeams ($Org: String!, $Logins: [String!], $After: String) {
 	organization(login: $Org) {
-		teams(first: 100, userLogins: [$Login]) {
+		teams(first: 100, after: $After, userLogins: $Logins) {
 			totalCount
+			pageInfo {
+				hasNextPage
+				endCursor
+			}
 			edges {
 				node {
 					name
+					slug
 					description
 				}
 			}
@@ -330,56 +407,37 @@ query MyTeams ($Org: String!, $Login: String!) {
 	return &retval, err
 }
 
-func MyBatch(
+// SearchPulls fetches the pages after the first one, for any of the searches
+// that github.GetPulls batches together with more than 100 results.
+// The batch itself is built at run time, since custom queries come from the
+// config, so keep the PullRequest fields here in step with the ones there.
+func SearchPulls(
 	ctx context.Context,
 	client graphql.Client,
-	meRequestedQuery string,
-	meMentionedQuery string,
-	teamAuthoredQuery string,
-	teamMentionedQuery string,
-	teamRequestedQuery string,
-) (*MyBatchResponse, error) {
+	query string,
+	after string,
+) (*SearchPullsResponse, error) {
 	variables := map[string]interface{}{
-		"MeRequestedQuery":   meRequestedQuery,
-		"MeMentionedQuery":   meMentionedQuery,
-		"TeamAuthoredQuery":  teamAuthoredQuery,
-		"TeamMentionedQuery": teamMentionedQuery,
-		"TeamRequestedQuery": teamRequestedQuery,
+		"Query": query,
 	}
 
-	var retval MyBatchResponse
+	var zero_after string
+	if after != zero_after {
+		variables["After"] = after
+	}
+
+	var retval SearchPullsResponse
 	err := client.MakeRequest(
 		ctx,
-		"MyBatch",
+		"SearchPulls",
 		`
-query MyBatch ($MeRequestedQuery: String!, $MeMentionedQuery: String!, $TeamAuthoredQuery: String!, $TeamMentionedQuery: String!, $TeamRequestedQuery: String!) {
-	merequested: search(query: $MeRequestedQuery, type: ISSUE, first: 100) {
+query SearchPulls ($Query: String!, $After: String) {
+	search(query: $Query, type: ISSUE, first: 100, after: $After) {
 		issueCount
-		edges {
-			node {
-				__typename
-				... on PullRequest {
-					number
-					title
-					repository {
-						nameWithOwner
-					}
-					author {
-						__typename
-						login
-					}
-					createdAt
-					mergedAt
-					url
-					changedFiles
-					additions
-					deletions
-				}
-			}
+		pageInfo {
+			hasNextPage
+			endCursor
 		}
-	}
-	mementioned: search(query: $MeMentionedQuery, type: ISSUE, first: 100) {
-		issueCount
 		edges {
 			node {
 				__typename
@@ -394,88 +452,103 @@ query MyBatch ($MeRequestedQuery: String!, $MeMentionedQuery: String!, $TeamAuth
 						login
 					}
 					createdAt
+					updatedAt
 					mergedAt
 					url
 					changedFiles
 					additions
 					deletions
-				}
-			}
-		}
-	}
-	teammates: search(query: $TeamAuthoredQuery, type: ISSUE, first: 100) {
-		issueCount
-		edges {
-			node {
-				__typename
-				... on PullRequest {
-					number
-					title
-					repository {
-						nameWithOwner
-					}
-					author {
-						__typename
-						login
+					isDraft
+					reviewDecision
+					mergeable
+					commits(last: 1) {
+						nodes {
+							commit {
+								pushedDate
+								committedDate
+								statusCheckRollup {
+									state
+								}
+							}
+						}
 					}
-					createdAt
-					mergedAt
-					url
-					changedFiles
-					additions
-					deletions
-				}
-			}
-		}
-	}
-	teammentions: search(query: $TeamMentionedQuery, type: ISSUE, first: 100) {
-		issueCount
-		edges {
-			node {
-				__typename
-				... on PullRequest {
-					number
-					title
-					repository {
-						nameWithOwner
+					timelineItems(last: 1, itemTypes: [REVIEW_REQUESTED_EVENT]) {
+						nodes {
+							__typename
+							... on ReviewRequestedEvent {
+								createdAt
+							}
+						}
 					}
-					author {
-						__typename
-						login
+					reviewRequests(first: 10) {
+						nodes {
+							requestedReviewer {
+								__typename
+								... on User {
+									login
+								}
+								... on Team {
+									slug
+								}
+								... on Mannequin {
+									login
+								}
+							}
+						}
 					}
-					createdAt
-					mergedAt
-					url
-					changedFiles
-					additions
-					deletions
 				}
 			}
 		}
 	}
-	teamrequested: search(query: $TeamRequestedQuery, type: ISSUE, first: 100) {
-		issueCount
-		edges {
-			node {
-				__typename
-				... on PullRequest {
-					number
-					title
-					repository {
-						nameWithOwner
-					}
-					author {
-						__typename
+}
+`,
+		&retval,
+		variables,
+	)
+	return &retval, err
+}
+
+func TeamMembers(
+	ctx context.Context,
+	client graphql.Client,
+	org string,
+	team string,
+	membership TeamMembershipType,
+	after string,
+) (*TeamMembersResponse, error) {
+	variables := map[string]interface{}{
+		"Org":        org,
+		"Team":       team,
+		"Membership": membership,
+	}
+
+	var zero_after string
+	if after != zero_after {
+		variables["After"] = after
+	}
+
+	var retval TeamMembersResponse
+	err := client.MakeRequest(
+		ctx,
+		"TeamMembers",
+		`
+query TeamMembers ($Org: String!, $Team: String!, $Membership: TeamMembershipType!, $After: String) {
+	organization(login: $Org) {
+		team(slug: $Team) {
+			members(first: 100, after: $After, membership: $Membership) {
+				pageInfo {
+					hasNextPage
+					endCursor
+				}
+				edges {
+					node {
+						name
 						login
 					}
-					createdAt
-					mergedAt
-					url
-					changedFiles
-					additions
-					deletions
 				}
 			}
+			name
+			description
 		}
 	}
 }
@@ -486,38 +559,40 @@ query MyBatch ($MeRequestedQuery: String!, $MeMentionedQuery: String!, $TeamAuth
 	return &retval, err
 }
 
-func TeamMembers(
+// ChildTeams lists the immediate child teams of a team, so they can be
+// walked recursively.
+func ChildTeams(
 	ctx context.Context,
 	client graphql.Client,
 	org string,
 	team string,
-) (*TeamMembersResponse, error) {
+	after string,
+) (*ChildTeamsResponse, error) {
 	variables := map[string]interface{}{
 		"Org":  org,
 		"Team": team,
 	}
 
-	var retval TeamMembersResponse
+	var zero_after string
+	if after != zero_after {
+		variables["After"] = after
+	}
+
+	var retval ChildTeamsResponse
 	err := client.MakeRequest(
 		ctx,
-		"TeamMembers",
+		"ChildTeams",
 		`
-query TeamMembers ($Org: String!, $Team: String!) {
+query ChildTeams ($Org: String!, $Team: String!, $After: String) {
 	organization(login: $Org) {
-		teams(first: 100, query: $Team) {
-			totalCount
-			edges {
-				node {
-					members {
-						edges {
-							node {
-								name
-								login
-							}
-						}
-					}
-					name
-					description
+		team(slug: $Team) {
+			childTeams(first: 100, after: $After) {
+				pageInfo {
+					hasNextPage
+					endCursor
+				}
+				nodes {
+					slug
 				}
 			}
 		}
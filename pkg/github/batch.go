@@ -0,0 +1,197 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Khan/genqlient/graphql"
+
+	"github.com/StevenACoffman/teamboard/pkg/types"
+)
+
+// Query is a custom search, shown as its own section of the board.
+type Query struct {
+	// Name identifies the query, and is the reason its pull requests
+	// are on the board. Like needs-qa
+	Name string
+	// Title is the heading of its section. Like Needs QA
+	Title string
+	// Query is a GitHub search, like label:needs-qa
+	// is:open is:pr archived:false are always added, as is org: unless
+	// the query already picks a repo:, org: or user:
+	Query string
+}
+
+// scoped matches the qualifiers that already say where to search.
+var scoped = regexp.MustCompile(`(^|\s)(repo|org|user):`)
+
+// hasDraftQualifier matches a draft: qualifier, or its negation.
+var hasDraftQualifier = regexp.MustCompile(`(^|\s)-?draft:`)
+
+// search is one of the searches batched together for the board.
+type search struct {
+	// alias is the GraphQL alias the search's results come back under
+	alias  string
+	query  string
+	reason types.Reason
+}
+
+// searchConnection is what each aliased search in the batch returns.
+type searchConnection struct {
+	IssueCount int          `json:"issueCount"`
+	PageInfo   pageInfo     `json:"pageInfo"`
+	Edges      []types.Edge `json:"edges"`
+}
+
+// pullRequestFields is the selection made for every pull request. It must
+// match the PullRequest selection in SearchPulls in genqlient.graphql,
+// plus the __typename genqlient adds, since both unmarshal into
+// types.PullRequest
+const pullRequestFields = `
+				__typename
+				... on PullRequest {
+					number
+					title
+					repository {
+						nameWithOwner
+					}
+					author {
+						__typename
+						login
+					}
+					createdAt
+					updatedAt
+					mergedAt
+					url
+					changedFiles
+					additions
+					deletions
+					isDraft
+					reviewDecision
+					mergeable
+					commits(last: 1) {
+						nodes {
+							commit {
+								pushedDate
+								committedDate
+								statusCheckRollup {
+									state
+								}
+							}
+						}
+					}
+					timelineItems(last: 1, itemTypes: [REVIEW_REQUESTED_EVENT]) {
+						nodes {
+							__typename
+							... on ReviewRequestedEvent {
+								createdAt
+							}
+						}
+					}
+					reviewRequests(first: 10) {
+						nodes {
+							requestedReviewer {
+								__typename
+								... on User {
+									login
+								}
+								... on Team {
+									slug
+								}
+								... on Mannequin {
+									login
+								}
+							}
+						}
+					}
+				}`
+
+// batchSearch makes the first page of every search in one GraphQL request,
+// with each search under its own alias. Since the searches vary (custom
+// queries come from the config), genqlient can't generate this one.
+func batchSearch(
+	ctx context.Context,
+	graphqlClient graphql.Client,
+	searches []search,
+) (map[string]searchConnection, error) {
+	var params, fields []string
+	variables := make(map[string]interface{}, len(searches))
+	for _, s := range searches {
+		params = append(params, fmt.Sprintf("$%s: String!", s.alias))
+		fields = append(fields, fmt.Sprintf(`
+	%s: search(query: $%s, type: ISSUE, first: 100) {
+		issueCount
+		pageInfo {
+			hasNextPage
+			endCursor
+		}
+		edges {
+			node {%s
+			}
+		}
+	}`, s.alias, s.alias, pullRequestFields))
+		variables[s.alias] = s.query
+	}
+
+	// What the batch cost comes back too, for the rate limit round
+	// tripper. It decodes into retval as an empty searchConnection, which
+	// nothing looks up.
+	fields = append(fields, `
+	rateLimit {
+		cost
+	}`)
+
+	// The operation keeps the name of the genqlient query it replaced,
+	// so things keyed on it, like cache TTLs, still apply.
+	query := fmt.Sprintf("query MyBatch (%s) {%s\n}\n",
+		strings.Join(params, ", "),
+		strings.Join(fields, ""),
+	)
+
+	retval := make(map[string]searchConnection, len(searches))
+	err := graphqlClient.MakeRequest(ctx, "MyBatch", query, &retval, variables)
+	if err != nil {
+		return nil, err
+	}
+	return retval, nil
+}
+
+// customSearches are the searches for the custom queries, aliased
+// query0, query1, and so on, since their names needn't be valid aliases.
+func customSearches(org string, queries []Query, qualifiers string) []search {
+	var searches []search
+	for i, q := range queries {
+		query := "is:open is:pr archived:false " + q.Query
+		if !scoped.MatchString(q.Query) {
+			query = fmt.Sprintf("is:open is:pr org:%s archived:false %s", org, q.Query)
+		}
+		searches = append(searches, search{
+			alias:  fmt.Sprintf("query%d", i),
+			query:  withQualifiers(query, qualifiers),
+			reason: types.Reason(q.Name),
+		})
+	}
+	return searches
+}
+
+// customSections are the board sections for the custom queries.
+func customSections(queries []Query) []types.Section {
+	var sections []types.Section
+	for _, q := range queries {
+		title := q.Title
+		if title == "" {
+			title = q.Name
+		}
+		sections = append(sections, types.Section{
+			ID:      q.Name,
+			Title:   title,
+			Empty:   "Nothing matches " + q.Query,
+			Reasons: []types.Reason{types.Reason(q.Name)},
+			// a query is asked for by name, so it has everything it finds
+			Overlaps: true,
+		})
+	}
+	return sections
+}
@@ -2,12 +2,17 @@ package github
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/Khan/genqlient/graphql"
+	"github.com/StevenACoffman/teamboard/pkg/calendar"
 	"github.com/StevenACoffman/teamboard/pkg/generated/genqlient"
+	"github.com/StevenACoffman/teamboard/pkg/logging"
+	"github.com/StevenACoffman/teamboard/pkg/middleware"
 	"github.com/StevenACoffman/teamboard/pkg/types"
 	"sort"
 	"strings"
+	"time"
 )
 
 func GetLogin(
@@ -15,53 +20,218 @@ func GetLogin(
 	graphqlClient graphql.Client,
 ) (string, error) {
 	myLoginResp, err := genqlient.MyLogin(ctx, graphqlClient)
-	if err != nil  {
+	if err != nil {
 		return "", err
 	}
-	return  myLoginResp.Viewer.Login, nil
+	return myLoginResp.Viewer.Login, nil
 }
-func GetTeams(ctx context.Context, graphqlClient graphql.Client, myLogin, org string) ([]string, error){
-	var myTeams []string
-
-	myTeamsResp, err := genqlient.MyTeams(ctx, graphqlClient, org, myLogin)
 
-	if err != nil {
-		return nil, err
+// GetTeams returns the slugs of the teams in org that myLogin belongs to,
+// or of every team in org if myLogin is empty.
+// Slugs (rather than display names) are what the search qualifiers expect.
+func GetTeams(ctx context.Context, graphqlClient graphql.Client, myLogin, org string) ([]string, error) {
+	ctx = middleware.WithOrg(ctx, org)
+	var myTeams []string
+	var logins []string
+	if myLogin != "" {
+		logins = []string{myLogin}
 	}
-	for _, edge := range myTeamsResp.Organization.Teams.Edges {
-		myTeams = append(myTeams, edge.Node.Name)
+
+	var after string
+	for {
+		myTeamsResp, err := genqlient.MyTeams(ctx, graphqlClient, org, logins, after)
+		if err != nil {
+			return nil, err
+		}
+		teams := myTeamsResp.Organization.Teams
+		for _, edge := range teams.Edges {
+			myTeams = append(myTeams, edge.Node.Slug)
+		}
+		if !teams.PageInfo.HasNextPage {
+			break
+		}
+		after = teams.PageInfo.EndCursor
 	}
 	return myTeams, nil
 }
-func GetOrgs(ctx context.Context, graphqlClient graphql.Client) ([]string, error){
+
+// GetOrgs returns the logins of every organization the viewer belongs to.
+func GetOrgs(ctx context.Context, graphqlClient graphql.Client) ([]string, error) {
 	var myOrgs []string
 
-	myOrgsResp, err := genqlient.MyOrgs(ctx, graphqlClient)
-	if err != nil {
-		return nil, err
-	}
-	for _, node := range myOrgsResp.Viewer.Organizations.Nodes {
-		myOrgs = append(myOrgs, node.Login)
+	var after string
+	for {
+		myOrgsResp, err := genqlient.MyOrgs(ctx, graphqlClient, after)
+		if err != nil {
+			return nil, err
+		}
+		orgs := myOrgsResp.Viewer.Organizations
+		for _, node := range orgs.Nodes {
+			myOrgs = append(myOrgs, node.Login)
+		}
+		if !orgs.PageInfo.HasNextPage {
+			break
+		}
+		after = orgs.PageInfo.EndCursor
 	}
 	return myOrgs, nil
 }
-func GetTeamMembers(ctx context.Context, graphqlClient graphql.Client, org string, team string) ( []string, error) {
-	fmt.Println("Getting team members for org: ", org, " team:", team)
+
+// ErrNotFound is returned (wrapped) when an org or team doesn't exist,
+// or isn't visible to the viewer.
+var ErrNotFound = errors.New("not found")
+
+// GetTeamMembers returns the logins of the members of the team in org
+// whose slug is exactly team, following the cursor through every page
+// of members.
+//
+// Normally only the team's direct members are returned. With childTeams,
+// the child teams are walked recursively as well, and the members of all
+// of them are returned, so parent teams with no direct members still work.
+func GetTeamMembers(
+	ctx context.Context,
+	graphqlClient graphql.Client,
+	org string,
+	team string,
+	childTeams bool,
+) ([]string, error) {
+	ctx = middleware.WithOrg(ctx, org)
+	logging.FromContext(ctx).Debug("getting team members",
+		"org", org,
+		"team", team,
+		"child_teams", childTeams,
+	)
+
+	if !childTeams {
+		return getMembers(ctx, graphqlClient, org, team, genqlient.TeamMembershipTypeImmediate)
+	}
 
 	var teammates []string
-	teamResp, teamErr := genqlient.TeamMembers(ctx, graphqlClient, org, team)
-	if teamErr != nil {
-		return nil, teamErr
+	seen := make(map[string]bool)
+	teams := []string{team}
+	visited := map[string]bool{team: true}
+	for len(teams) > 0 {
+		slug := teams[0]
+		teams = teams[1:]
+
+		members, err := getMembers(ctx, graphqlClient, org, slug, genqlient.TeamMembershipTypeAll)
+		if err != nil {
+			return nil, err
+		}
+		for _, login := range members {
+			if !seen[login] {
+				seen[login] = true
+				teammates = append(teammates, login)
+			}
+		}
+
+		children, err := getChildTeams(ctx, graphqlClient, org, slug)
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range children {
+			if !visited[child] {
+				visited[child] = true
+				teams = append(teams, child)
+			}
+		}
 	}
+	return teammates, nil
+}
 
-	for _, team := range teamResp.Organization.Teams.Edges {
-		for _, edge := range team.Node.Members.Edges {
+// getMembers returns the logins of the members of a single team.
+func getMembers(
+	ctx context.Context,
+	graphqlClient graphql.Client,
+	org string,
+	team string,
+	membership genqlient.TeamMembershipType,
+) ([]string, error) {
+	var teammates []string
+	var after string
+	for {
+		teamResp, teamErr := genqlient.TeamMembers(ctx, graphqlClient, org, team, membership, after)
+		if teamErr != nil {
+			return nil, teamErr
+		}
+		if teamResp.Organization.Team == nil {
+			return nil, fmt.Errorf("team %s/%s: %w", org, team, ErrNotFound)
+		}
+
+		members := teamResp.Organization.Team.Members
+		for _, edge := range members.Edges {
 			teammates = append(teammates, edge.Node.Login)
 		}
+		if !members.PageInfo.HasNextPage {
+			break
+		}
+		after = members.PageInfo.EndCursor
 	}
 	return teammates, nil
 }
 
+// getChildTeams returns the slugs of the immediate child teams of a team.
+func getChildTeams(ctx context.Context, graphqlClient graphql.Client, org string, team string) ([]string, error) {
+	var children []string
+	var after string
+	for {
+		resp, err := genqlient.ChildTeams(ctx, graphqlClient, org, team, after)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Organization.Team == nil {
+			return nil, fmt.Errorf("team %s/%s: %w", org, team, ErrNotFound)
+		}
+
+		childTeams := resp.Organization.Team.ChildTeams
+		for _, node := range childTeams.Nodes {
+			children = append(children, node.Slug)
+		}
+		if !childTeams.PageInfo.HasNextPage {
+			break
+		}
+		after = childTeams.PageInfo.EndCursor
+	}
+	return children, nil
+}
+
+// DefaultMaxPages is how many pages of 100 results GetPulls will fetch
+// for each search when SearchOptions.MaxPages is not set.
+const DefaultMaxPages = 10
+
+// SearchOptions tune the searches GetPulls makes.
+type SearchOptions struct {
+	// MaxPages is the most pages of 100 results to fetch for each search.
+	// If it is zero, DefaultMaxPages is used.
+	MaxPages int
+	// ChildTeams counts the members of child teams as teammates too.
+	ChildTeams bool
+	// Drafts includes the draft pull requests the team wrote, or that are
+	// in Repos or Queries, which are otherwise left out unless a query asks
+	// for them with a draft: qualifier. Drafts that want a review from, or
+	// mention, you or the team are always included.
+	Drafts bool
+	// Repos are extra repositories, like Khan/webapp, whose open pull
+	// requests are all wanted, whoever wrote them.
+	Repos []string
+	// Users are extra people whose pull requests count as the team's.
+	Users []string
+	// Qualifiers are added to every search, e.g. -label:wip
+	Qualifiers string
+	// Queries are custom searches, each shown as its own section.
+	Queries []Query
+	// SLAs are how long a pull request can wait, for each reason it is on
+	// the board, before it is overdue. Reasons without one never are.
+	SLAs map[types.Reason]time.Duration
+	// Calendar is when people work, for ages and SLAs. If it is nil,
+	// every hour counts.
+	Calendar *calendar.Calendar
+}
+
+// GetPulls searches org for the open pull requests that you or team (whose
+// members are teammates) are involved in, and splits them into the
+// sections of a board. An empty team only searches for your own, and an
+// empty myLogin only for the team's.
 func GetPulls(
 	ctx context.Context,
 	graphqlClient graphql.Client,
@@ -69,115 +239,336 @@ func GetPulls(
 	org string,
 	team string,
 	teammates []string,
-) ([]types.PullRequest, error) {
-
-	teamAuthoredQuery := fmt.Sprintf(
-		"is:open is:pr org:%s archived:false draft:false author:%s",
-		org,
-		strings.Join(teammates, " author:"),
-	)
+	opts SearchOptions,
+) (*types.Board, error) {
+	pulls, err := searchPulls(ctx, graphqlClient, myLogin, org, team, teammates, opts)
+	if err != nil {
+		return nil, err
+	}
+	return buildBoard(pulls, myLogin, opts), nil
+}
 
-	meRequestedQuery := fmt.Sprintf(
-		"is:open is:pr org:%s  archived:false review-requested:%s",
-		org,
-		myLogin,
-	)
-	meMentionedQuery := fmt.Sprintf(
-		"is:open is:pr org:%s archived:false mentions:%s",
-		org,
-		myLogin,
-	)
+// searchPulls makes every search for org, and returns what they found,
+// each tagged with the reason the search was made.
+func searchPulls(
+	ctx context.Context,
+	graphqlClient graphql.Client,
+	myLogin string,
+	org string,
+	team string,
+	teammates []string,
+	opts SearchOptions,
+) ([]types.PullRequest, error) {
+	// with a GitHub App, searches use its installation in org
+	ctx = middleware.WithOrg(ctx, org)
+	searches := boardSearches(myLogin, org, team, teammates, opts)
 
-	teamMentionedQuery := fmt.Sprintf(
-		"is:open is:pr org:%s archived:false team:%s/%s",
-		org,
-		org,
-		team,
-	)
-	teamRequestedQuery := fmt.Sprintf(
-		"is:open is:pr org:%s archived:false team-review-requested:%s/%s",
-		org,
-		org,
-		team,
-	)
+	resp, err := batchSearch(ctx, graphqlClient, searches)
+	if err != nil {
+		return nil, err
+	}
 
-	resp, err := genqlient.MyBatch(
-		ctx,
-		graphqlClient,
-		meRequestedQuery,
-		meMentionedQuery,
-		teamAuthoredQuery,
-		teamMentionedQuery,
-		teamRequestedQuery,
-	)
 	var pulls []types.PullRequest
+	for _, search := range searches {
+		first := resp[search.alias]
+		edges, err := searchRemaining(ctx, graphqlClient, search.query, first.Edges, first.PageInfo, opts.MaxPages)
+		if err != nil {
+			return nil, err
+		}
+		pulls = appendPulls(pulls, edges, search.reason)
+	}
+	return pulls, nil
+}
 
-	if err != nil {
-		return nil, err
+// boardSearches are the searches for the board of team in org.
+func boardSearches(
+	myLogin string,
+	org string,
+	team string,
+	teammates []string,
+	opts SearchOptions,
+) []search {
+	var searches []search
+	// a GitHub App has no one signed in to search for
+	if myLogin != "" {
+		meRequestedQuery := fmt.Sprintf(
+			"is:open is:pr org:%s  archived:false review-requested:%s",
+			org,
+			myLogin,
+		)
+		meMentionedQuery := fmt.Sprintf(
+			"is:open is:pr org:%s archived:false mentions:%s",
+			org,
+			myLogin,
+		)
+		searches = append(searches,
+			search{"merequested", meRequestedQuery, types.ReasonReviewRequested},
+			search{"mementioned", meMentionedQuery, types.ReasonMentioned},
+		)
 	}
-	for _, edge := range resp.Mementioned.Edges {
-		// s, ok :=
-		// edge.Node.(*genqlient.MyBatchMementionedSearchResultItemConnectionEdgesSearchResultItemEdgeNodePullRequest)
-		s, ok := edge.Node.(*types.PullRequest)
-		if ok {
-			pulls = append(pulls, *s)
+	if authors := mergeLogins(teammates, opts.Users); len(authors) > 0 {
+		teamAuthoredQuery := fmt.Sprintf(
+			"is:open is:pr org:%s archived:false author:%s",
+			org,
+			strings.Join(authors, " author:"),
+		)
+		searches = append(searches, search{"teammates", teamAuthoredQuery, types.ReasonTeamAuthored})
+	}
+	if team != "" {
+		teamMentionedQuery := fmt.Sprintf(
+			"is:open is:pr org:%s archived:false team:%s/%s",
+			org,
+			org,
+			team,
+		)
+		teamRequestedQuery := fmt.Sprintf(
+			"is:open is:pr org:%s archived:false team-review-requested:%s/%s",
+			org,
+			org,
+			team,
+		)
+		searches = append(searches,
+			search{"teammentions", teamMentionedQuery, types.ReasonTeamMentioned},
+			search{"teamrequested", teamRequestedQuery, types.ReasonTeamRequested},
+		)
+	}
+	if len(opts.Repos) > 0 {
+		// not limited to org, since the repos needn't be in it
+		reposQuery := fmt.Sprintf(
+			"is:open is:pr archived:false repo:%s",
+			strings.Join(opts.Repos, " repo:"),
+		)
+		searches = append(searches, search{"repos", reposQuery, types.ReasonRepo})
+	}
+	for i := range searches {
+		searches[i].query = withQualifiers(searches[i].query, opts.Qualifiers)
+	}
+	searches = append(searches, customSearches(org, opts.Queries, opts.Qualifiers)...)
+	if !opts.Drafts {
+		for i := range searches {
+			if !asksForYou(searches[i].reason) {
+				searches[i].query = withoutDrafts(searches[i].query)
+			}
 		}
 	}
-	for _, edge := range resp.Merequested.Edges {
+	return searches
+}
 
-		s, ok := edge.Node.(*types.PullRequest)
-		if ok {
-			pulls = append(pulls, *s)
+// mergePulls sorts pulls from most recent to oldest. The same pull request
+// is often found by more than one search, so it keeps one of each, with all
+// the reasons it was found.
+func mergePulls(pulls []types.PullRequest) []types.PullRequest {
+	sort.SliceStable(pulls, func(i, j int) bool {
+		// results in most recent to oldest
+		return pulls[i].CreatedAt.After(pulls[j].CreatedAt)
+	})
+	return removeDuplicateValues(pulls)
+}
+
+// boardSections are the sections of a board searched with opts. Without
+// myLogin, there is no one whose review is needed.
+func boardSections(myLogin string, opts SearchOptions) []types.Section {
+	var s []types.Section
+	for _, section := range sections {
+		if myLogin != "" || section.ID != "review-requested" {
+			s = append(s, section)
 		}
 	}
-	for _, edge := range resp.Teammates.Edges {
+	s = append(s, customSections(opts.Queries)...)
+	if len(opts.Repos) > 0 {
+		s = append(s, repoSection)
+	}
+	return s
+}
 
-		// s, ok :=
-		// edge.Node.(*genqlient.MyBatchTeammatesSearchResultItemConnectionEdgesSearchResultItemEdgeNodePullRequest)
-		s, ok := edge.Node.(*types.PullRequest)
-		if ok {
-			pulls = append(pulls, *s)
+// sections are the sections of the board, in priority order.
+var sections = []types.Section{
+	{
+		ID:      "review-requested",
+		Title:   "Needs your review",
+		Empty:   "Nobody is waiting on your review.",
+		Reasons: []types.Reason{types.ReasonReviewRequested},
+	},
+	{
+		ID:      "team-review-requested",
+		Title:   "Needs team review",
+		Empty:   "Nobody is waiting on your team's review.",
+		Reasons: []types.Reason{types.ReasonTeamRequested},
+	},
+	{
+		ID:      "team-authored",
+		Title:   "Your team's open PRs",
+		Empty:   "Your team has no open pull requests.",
+		Reasons: []types.Reason{types.ReasonTeamAuthored},
+	},
+	{
+		ID:      "mentioned",
+		Title:   "You were mentioned",
+		Empty:   "Nobody has mentioned you or your team.",
+		Reasons: []types.Reason{types.ReasonMentioned, types.ReasonTeamMentioned},
+	},
+}
+
+// repoSection is only on the board when there are SearchOptions.Repos
+var repoSection = types.Section{
+	ID:      "repo",
+	Title:   "Watched repositories",
+	Empty:   "There are no other open pull requests in the repositories you watch.",
+	Reasons: []types.Reason{types.ReasonRepo},
+}
+
+// buildBoard merges the pulls found by every search into a board,
+// with the most overdue first in each section.
+func buildBoard(pulls []types.PullRequest, myLogin string, opts SearchOptions) *types.Board {
+	cal := opts.Calendar
+	if cal == nil {
+		cal = calendar.Always
+	}
+	now := time.Now()
+
+	pulls = mergePulls(pulls)
+	setDue(pulls, myLogin, opts.SLAs, cal, now)
+	board := newBoard(pulls, boardSections(myLogin, opts))
+	board.Drafts = opts.Drafts
+	sortOverdue(board, now)
+	return board
+}
+
+// newBoard splits pulls into the board's sections. Like Phabricator, each
+// pull request is only shown once, in the first section it matches, except
+// that sections which Overlap (the custom queries) have every pull request
+// they match, wherever else it is.
+func newBoard(pulls []types.PullRequest, sections []types.Section) *types.Board {
+	board := &types.Board{Sections: make([]types.Section, len(sections))}
+	copy(board.Sections, sections)
+
+	for _, pull := range pulls {
+		placed := false
+		for i := range board.Sections {
+			section := &board.Sections[i]
+			if (!placed || section.Overlaps) && section.Matches(pull) {
+				section.Pulls = append(section.Pulls, pull)
+				placed = true
+			}
 		}
 	}
-	for _, edge := range resp.Teammentions.Edges {
+	return board
+}
 
-		// s, ok :=
-		// edge.Node.(*genqlient.MyBatchTeammentionsSearchResultItemConnectionEdgesSearchResultItemEdgeNodePullRequest)
-		s, ok := edge.Node.(*types.PullRequest)
-		if ok {
-			pulls = append(pulls, *s)
+// withQualifiers adds the extra search qualifiers, if any, to query.
+func withQualifiers(query, qualifiers string) string {
+	if qualifiers == "" {
+		return query
+	}
+	return query + " " + qualifiers
+}
+
+// asksForYou reports whether a search is for pull requests that want
+// something of you or your team, which are shown even if they're drafts.
+func asksForYou(reason types.Reason) bool {
+	switch reason {
+	case types.ReasonReviewRequested, types.ReasonMentioned,
+		types.ReasonTeamRequested, types.ReasonTeamMentioned:
+		return true
+	}
+	return false
+}
+
+// withoutDrafts leaves draft pull requests out of query, unless it
+// already says whether it wants them.
+func withoutDrafts(query string) string {
+	if hasDraftQualifier.MatchString(query) {
+		return query
+	}
+	return query + " draft:false"
+}
+
+// mergeLogins is teammates followed by any users who aren't among them.
+func mergeLogins(teammates, users []string) []string {
+	logins := append([]string(nil), teammates...)
+	seen := make(map[string]bool, len(teammates))
+	for _, login := range teammates {
+		seen[strings.ToLower(login)] = true
+	}
+	for _, login := range users {
+		if !seen[strings.ToLower(login)] {
+			seen[strings.ToLower(login)] = true
+			logins = append(logins, login)
 		}
+	}
+	return logins
+}
 
+// pageInfo is the cursor information common to every search connection,
+// whichever query alias it came from.
+type pageInfo struct {
+	HasNextPage bool
+	EndCursor   string
+}
+
+// searchRemaining follows the cursor of a search whose first page was edges,
+// until the search is exhausted or maxPages pages have been fetched.
+func searchRemaining(
+	ctx context.Context,
+	graphqlClient graphql.Client,
+	query string,
+	edges []types.Edge,
+	page pageInfo,
+	maxPages int,
+) ([]types.Edge, error) {
+	if maxPages <= 0 {
+		maxPages = DefaultMaxPages
 	}
-	for _, edge := range resp.Teamrequested.Edges {
-		// s, ok := (&edge).(types.EdgeNodePullRequest)
+	for pages := 1; page.HasNextPage; pages++ {
+		if pages >= maxPages {
+			logging.FromContext(ctx).Warn("stopped before the end of search results",
+				"pages", pages,
+				"query", query,
+			)
+			break
+		}
+		resp, err := genqlient.SearchPulls(ctx, graphqlClient, query, page.EndCursor)
+		if err != nil {
+			return nil, err
+		}
+		edges = append(edges, resp.Search.Edges...)
+		page = pageInfo(resp.Search.PageInfo)
+	}
+	return edges, nil
+}
+
+// appendPulls appends the pull requests among edges to pulls,
+// tagged with the reason the search found them.
+func appendPulls(pulls []types.PullRequest, edges []types.Edge, reason types.Reason) []types.PullRequest {
+	for _, edge := range edges {
 		s, ok := edge.Node.(*types.PullRequest)
 		if ok {
-			pulls = append(pulls, *s)
+			pull := *s
+			pull.Reasons = nil
+			pull.AddReason(reason)
+			pulls = append(pulls, pull)
 		}
 	}
-	sort.Slice(pulls, func(i, j int) bool {
-		// results in most recent to oldest
-		return pulls[i].CreatedAt.After(pulls[j].CreatedAt)
-	})
-	// Do we really want to do this?
-	// Or should we have added a reason / category to them above instead?
-	pulls = removeDuplicateValues(pulls)
-	return pulls, nil
+	return pulls
 }
 
 func removeDuplicateValues(pulls []types.PullRequest) []types.PullRequest {
-	keys := make(map[string]bool)
+	keys := make(map[string]int)
 	var list []types.PullRequest
 
 	// If the key(values of the slice) is not equal
 	// to the already present value in new slice (list)
-	// then we append it. else we jump on another element.
+	// then we append it. else we merge its reasons into
+	// the one already there and jump on another element.
 	for _, entry := range pulls {
-		if _, value := keys[entry.Url]; !value {
-			keys[entry.Url] = true
-			list = append(list, entry)
+		if i, value := keys[entry.Url]; value {
+			for _, reason := range entry.Reasons {
+				list[i].AddReason(reason)
+			}
+			continue
 		}
+		keys[entry.Url] = len(list)
+		list = append(list, entry)
 	}
 	return list
 }
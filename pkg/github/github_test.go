@@ -0,0 +1,95 @@
+package github
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/StevenACoffman/teamboard/pkg/types"
+)
+
+func TestBuildBoardCustomQuerySections(t *testing.T) {
+	needsQA := Query{Name: "needs-qa", Title: "Needs QA", Query: "label:needs-qa"}
+	pulls := []types.PullRequest{
+		// found by both the team-authored search and the custom query
+		{Url: "https://github.com/Khan/webapp/pull/1", Reasons: []types.Reason{types.ReasonTeamAuthored}},
+		{Url: "https://github.com/Khan/webapp/pull/1", Reasons: []types.Reason{"needs-qa"}},
+		{Url: "https://github.com/Khan/webapp/pull/2", Reasons: []types.Reason{types.ReasonTeamAuthored}},
+		{Url: "https://github.com/Khan/webapp/pull/3", Reasons: []types.Reason{"needs-qa"}},
+		// found by two built in searches
+		{Url: "https://github.com/Khan/webapp/pull/4", Reasons: []types.Reason{types.ReasonTeamRequested}},
+		{Url: "https://github.com/Khan/webapp/pull/4", Reasons: []types.Reason{types.ReasonTeamAuthored}},
+	}
+
+	board := buildBoard(pulls, "octocat", SearchOptions{Queries: []Query{needsQA}})
+
+	want := map[string][]string{
+		"review-requested":      nil,
+		"team-review-requested": {"4"},
+		"team-authored":         {"1", "2"},
+		"mentioned":             nil,
+		"needs-qa":              {"1", "3"},
+	}
+	if len(board.Sections) != len(want) {
+		t.Fatalf("got %d sections, want %d", len(board.Sections), len(want))
+	}
+	for _, section := range board.Sections {
+		var got []string
+		for _, pull := range section.Pulls {
+			got = append(got, pull.Url[len("https://github.com/Khan/webapp/pull/"):])
+		}
+		if !equalStrings(got, want[section.ID]) {
+			t.Errorf("section %s has %v, want %v", section.ID, got, want[section.ID])
+		}
+	}
+
+	if got := len(board.Pulls()); got != 4 {
+		t.Errorf("board has %d pull requests, want each of the 4 once", got)
+	}
+}
+
+func TestBoardSearchesDrafts(t *testing.T) {
+	opts := SearchOptions{
+		Repos: []string{"Khan/webapp"},
+		Queries: []Query{
+			{Name: "needs-qa", Query: "label:needs-qa"},
+			{Name: "wip", Query: "draft:true author:octocat"},
+		},
+	}
+	// drafts that want something of you or the team are always shown
+	wantHidden := map[string]bool{
+		"merequested":   false,
+		"mementioned":   false,
+		"teammates":     true,
+		"teammentions":  false,
+		"teamrequested": false,
+		"repos":         true,
+		"query0":        true,
+		"query1":        false,
+	}
+
+	for _, drafts := range []bool{false, true} {
+		opts.Drafts = drafts
+		searches := boardSearches("octocat", "Khan", "districts", []string{"octocat"}, opts)
+		if len(searches) != len(wantHidden) {
+			t.Fatalf("got %d searches, want %d", len(searches), len(wantHidden))
+		}
+		for _, search := range searches {
+			hidden := strings.Contains(search.query, "draft:false")
+			if want := wantHidden[search.alias] && !drafts; hidden != want {
+				t.Errorf("drafts %t: %s hides drafts: %t, want %t, in %q", drafts, search.alias, hidden, want, search.query)
+			}
+		}
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,116 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/Khan/genqlient/graphql"
+
+	"github.com/StevenACoffman/teamboard/pkg/logging"
+	"github.com/StevenACoffman/teamboard/pkg/types"
+)
+
+// GetBoard is the board for team in orgs: GetPulls for one org, with the
+// team's members looked up, or GetOrgsPulls for several, where the team is
+// optional.
+func GetBoard(
+	ctx context.Context,
+	graphqlClient graphql.Client,
+	myLogin string,
+	orgs []string,
+	team string,
+	opts SearchOptions,
+) (*types.Board, error) {
+	if len(orgs) > 1 {
+		return GetOrgsPulls(ctx, graphqlClient, myLogin, orgs, team, opts)
+	}
+
+	org := orgs[0]
+	teammates, err := GetTeamMembers(ctx, graphqlClient, org, team, opts.ChildTeams)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Debug("got teammates",
+		"org", org,
+		"team", team,
+		"teammates", teammates,
+	)
+	return GetPulls(ctx, graphqlClient, myLogin, org, team, teammates, opts)
+}
+
+// GetOrgsPulls is GetPulls for several orgs at once, merged into a single
+// board. The orgs are searched concurrently. Team slugs belong to an org,
+// so team is looked up in each of them, and an org without a team by that
+// name only gets the searches for your own pull requests.
+func GetOrgsPulls(
+	ctx context.Context,
+	graphqlClient graphql.Client,
+	myLogin string,
+	orgs []string,
+	team string,
+	opts SearchOptions,
+) (*types.Board, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([][]types.PullRequest, len(orgs))
+	errs := make([]error, len(orgs))
+	var wg sync.WaitGroup
+	for i, org := range orgs {
+		wg.Add(1)
+		go func(i int, org string) {
+			defer wg.Done()
+			results[i], errs[i] = orgPulls(ctx, graphqlClient, myLogin, org, team, opts)
+			if errs[i] != nil {
+				// no point finishing the others
+				cancel()
+			}
+		}(i, org)
+	}
+	wg.Wait()
+
+	var pulls []types.PullRequest
+	for i := range orgs {
+		if errs[i] != nil && !errors.Is(errs[i], context.Canceled) {
+			return nil, errs[i]
+		}
+		pulls = append(pulls, results[i]...)
+	}
+	// every error was a cancellation, so report the first
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Repos and custom queries with their own repo: or org: are searched
+	// for every org, so there are duplicates across orgs to merge too.
+	board := buildBoard(pulls, myLogin, opts)
+	board.Orgs = orgs
+	return board, nil
+}
+
+// orgPulls looks up team's members in org, if there is such a team,
+// and makes every search for org.
+func orgPulls(
+	ctx context.Context,
+	graphqlClient graphql.Client,
+	myLogin string,
+	org string,
+	team string,
+	opts SearchOptions,
+) ([]types.PullRequest, error) {
+	var teammates []string
+	if team != "" {
+		var err error
+		teammates, err = GetTeamMembers(ctx, graphqlClient, org, team, opts.ChildTeams)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			team = ""
+		case err != nil:
+			return nil, err
+		}
+	}
+	return searchPulls(ctx, graphqlClient, myLogin, org, team, teammates, opts)
+}
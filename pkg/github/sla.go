@@ -0,0 +1,60 @@
+package github
+
+import (
+	"sort"
+	"time"
+
+	"github.com/StevenACoffman/teamboard/pkg/calendar"
+	"github.com/StevenACoffman/teamboard/pkg/types"
+)
+
+// setDue sets Age and WaitingSince on every pull request, and Due on those
+// with an SLA for any of their reasons. With more than one, the shortest
+// wins. Both count working time in the time zone of whoever the pull request
+// is waiting on: you, if your review was requested, or else its author.
+func setDue(
+	pulls []types.PullRequest,
+	myLogin string,
+	slas map[types.Reason]time.Duration,
+	cal *calendar.Calendar,
+	now time.Time,
+) {
+	for i := range pulls {
+		pull := &pulls[i]
+		pull.SetWaitingSince()
+
+		waitingOn := pull.Author.Login
+		if pull.HasReason(types.ReasonReviewRequested) {
+			waitingOn = myLogin
+		}
+		personal := cal.For(waitingOn)
+		pull.Age = personal.Format(personal.Elapsed(pull.CreatedAt, now))
+
+		var sla time.Duration
+		for _, reason := range pull.Reasons {
+			if d := slas[reason]; d > 0 && (sla == 0 || d < sla) {
+				sla = d
+			}
+		}
+		if sla > 0 {
+			due := personal.Add(pull.WaitingSince, sla)
+			pull.Due = &due
+		}
+	}
+}
+
+// sortOverdue moves the overdue pull requests to the top of each section,
+// the most overdue first. The rest keep their order.
+func sortOverdue(board *types.Board, now time.Time) {
+	for _, section := range board.Sections {
+		pulls := section.Pulls
+		sort.SliceStable(pulls, func(i, j int) bool {
+			iOverdue := pulls[i].Due != nil && now.After(*pulls[i].Due)
+			jOverdue := pulls[j].Due != nil && now.After(*pulls[j].Due)
+			if iOverdue != jOverdue {
+				return iOverdue
+			}
+			return iOverdue && pulls[i].Due.Before(*pulls[j].Due)
+		})
+	}
+}
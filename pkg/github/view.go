@@ -0,0 +1,209 @@
+package github
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/StevenACoffman/teamboard/pkg/types"
+)
+
+// SortBy is what the pull requests on a board can be sorted by.
+type SortBy string
+
+const (
+	// SortAge is by when they were opened, oldest first.
+	SortAge SortBy = "age"
+	// SortSize is by lines added and deleted, biggest first.
+	SortSize SortBy = "size"
+	// SortRepo is by repository name, A to Z.
+	SortRepo SortBy = "repo"
+	// SortAuthor is by author login, A to Z.
+	SortAuthor SortBy = "author"
+	// SortUpdated is by when they were last updated, most recent first.
+	SortUpdated SortBy = "updated"
+)
+
+// SortKeys are everything a board can be sorted by.
+var SortKeys = []SortBy{SortAge, SortSize, SortRepo, SortAuthor, SortUpdated}
+
+// Sort is how to order the pull requests in each section of a board.
+// The zero Sort leaves the board's own order alone: overdue first,
+// then most recently opened.
+type Sort struct {
+	By SortBy
+	// Reverse turns the order of By around, e.g. newest first for SortAge.
+	Reverse bool
+}
+
+// ParseSort is the Sort for by, like size, and order, which is asc or
+// desc. An empty order is whichever way by goes by default.
+func ParseSort(by, order string) (Sort, error) {
+	if by == "" {
+		return Sort{}, nil
+	}
+	s := Sort{By: SortBy(strings.ToLower(by))}
+	known := false
+	for _, key := range SortKeys {
+		known = known || key == s.By
+	}
+	if !known {
+		return Sort{}, fmt.Errorf("can't sort by %q, only %v", by, SortKeys)
+	}
+
+	// the default order of each key, as asc or desc
+	natural := "asc"
+	if s.By == SortSize || s.By == SortUpdated {
+		natural = "desc"
+	}
+	switch strings.ToLower(order) {
+	case "", natural:
+	case "asc", "desc":
+		s.Reverse = true
+	default:
+		return Sort{}, fmt.Errorf("order must be asc or desc, not %q", order)
+	}
+	return s, nil
+}
+
+// less reports whether a goes before b, ignoring Reverse.
+func (s Sort) less(a, b *types.PullRequest) bool {
+	switch s.By {
+	case SortAge:
+		return a.CreatedAt.Before(b.CreatedAt)
+	case SortSize:
+		return a.Additions+a.Deletions > b.Additions+b.Deletions
+	case SortRepo:
+		return strings.ToLower(a.Repository.NameWithOwner) < strings.ToLower(b.Repository.NameWithOwner)
+	case SortAuthor:
+		return strings.ToLower(a.Author.Login) < strings.ToLower(b.Author.Login)
+	case SortUpdated:
+		return a.UpdatedAt.After(b.UpdatedAt)
+	default:
+		return false
+	}
+}
+
+// Pulls sorts pulls in place. Ties keep the order they were in.
+func (s Sort) Pulls(pulls []types.PullRequest) {
+	if s.By == "" {
+		return
+	}
+	sort.SliceStable(pulls, func(i, j int) bool {
+		if s.Reverse {
+			return s.less(&pulls[j], &pulls[i])
+		}
+		return s.less(&pulls[i], &pulls[j])
+	})
+}
+
+// Board sorts each section of board.
+func (s Sort) Board(board *types.Board) {
+	for _, section := range board.Sections {
+		s.Pulls(section.Pulls)
+	}
+}
+
+// Filter picks which pull requests to keep. Each field that is set
+// must match, and it matches if any of its values do.
+type Filter struct {
+	// Repos are repositories, like Khan/webapp
+	Repos []string
+	// Authors are author logins.
+	Authors []string
+	// Sizes are size buckets, like xs
+	Sizes []types.Size
+	// Reasons are reasons for being on the board, like review-requested
+	Reasons []types.Reason
+}
+
+// Empty reports whether the filter keeps everything.
+func (f Filter) Empty() bool {
+	return len(f.Repos) == 0 && len(f.Authors) == 0 && len(f.Sizes) == 0 && len(f.Reasons) == 0
+}
+
+// Match reports whether pull passes the filter.
+func (f Filter) Match(pull *types.PullRequest) bool {
+	return anyFold(f.Repos, pull.Repository.NameWithOwner) &&
+		anyFold(f.Authors, pull.Author.Login) &&
+		f.matchSize(pull) &&
+		f.matchReason(pull)
+}
+
+func (f Filter) matchSize(pull *types.PullRequest) bool {
+	if len(f.Sizes) == 0 {
+		return true
+	}
+	size := pull.Size()
+	for _, s := range f.Sizes {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
+
+func (f Filter) matchReason(pull *types.PullRequest) bool {
+	if len(f.Reasons) == 0 {
+		return true
+	}
+	for _, reason := range f.Reasons {
+		if pull.HasReason(reason) {
+			return true
+		}
+	}
+	return false
+}
+
+// anyFold reports whether s is any of values, ignoring case,
+// or there are no values.
+func anyFold(values []string, s string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Pulls are the pulls that pass the filter.
+func (f Filter) Pulls(pulls []types.PullRequest) []types.PullRequest {
+	if f.Empty() {
+		return pulls
+	}
+	var kept []types.PullRequest
+	for i := range pulls {
+		if f.Match(&pulls[i]) {
+			kept = append(kept, pulls[i])
+		}
+	}
+	return kept
+}
+
+// Board drops the pulls that don't pass the filter from every section
+// of board.
+func (f Filter) Board(board *types.Board) {
+	for i := range board.Sections {
+		board.Sections[i].Pulls = f.Pulls(board.Sections[i].Pulls)
+	}
+}
+
+// ParseSizes are the sizes called names, like xs or xl
+func ParseSizes(names []string) ([]types.Size, error) {
+	var sizes []types.Size
+	for _, name := range names {
+		size := types.Size(strings.ToLower(name))
+		known := false
+		for _, s := range types.Sizes {
+			known = known || s == size
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown size %q, must be one of %v", name, types.Sizes)
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
@@ -0,0 +1,257 @@
+// package logging - a small structured, leveled logger, writing one
+// record per line as logfmt or JSON, so logs can be read by people and
+// by log collectors alike.
+
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is how important a record is. Records below a Logger's level
+// are dropped.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return "Level(" + strconv.Itoa(int(l)) + ")"
+	}
+	return levelNames[l]
+}
+
+// ParseLevel is the Level called name: debug, info, warn or error.
+func ParseLevel(name string) (Level, error) {
+	for i, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return Level(i), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q, must be one of %v", name, levelNames)
+}
+
+// Format is how records are written out.
+type Format string
+
+const (
+	// FormatLogfmt is key=value pairs, like level=info msg=started
+	FormatLogfmt Format = "logfmt"
+	// FormatJSON is a JSON object per record.
+	FormatJSON Format = "json"
+)
+
+// Formats are every Format.
+var Formats = []Format{FormatLogfmt, FormatJSON}
+
+// ParseFormat is the Format called name: logfmt or json.
+func ParseFormat(name string) (Format, error) {
+	for _, format := range Formats {
+		if strings.EqualFold(name, string(format)) {
+			return format, nil
+		}
+	}
+	return FormatLogfmt, fmt.Errorf("unknown log format %q, must be one of %v", name, Formats)
+}
+
+// output is where a Logger and everything made from it With writes.
+type output struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format Format
+	level  Level
+}
+
+// Logger writes structured records: a time, a level, a message, and
+// key/value pairs. A nil *Logger drops everything, so it's always safe
+// to log.
+type Logger struct {
+	out    *output
+	fields []interface{}
+}
+
+// New is a Logger writing records at level or above to w, in format.
+func New(w io.Writer, format Format, level Level) *Logger {
+	return &Logger{out: &output{w: w, format: format, level: level}}
+}
+
+// Default is where records go when nothing else has been set up:
+// logfmt to stderr, from info up.
+var Default = New(os.Stderr, FormatLogfmt, LevelInfo)
+
+// With is a Logger that adds keyvals, alternating keys and values,
+// to every record.
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	if l == nil {
+		return nil
+	}
+	fields := make([]interface{}, 0, len(l.fields)+len(keyvals))
+	fields = append(fields, l.fields...)
+	fields = append(fields, keyvals...)
+	return &Logger{out: l.out, fields: fields}
+}
+
+// Enabled reports whether records at level are written.
+func (l *Logger) Enabled(level Level) bool {
+	return l != nil && level >= l.out.level
+}
+
+func (l *Logger) Debug(msg string, keyvals ...interface{}) { l.log(LevelDebug, msg, keyvals) }
+func (l *Logger) Info(msg string, keyvals ...interface{})  { l.log(LevelInfo, msg, keyvals) }
+func (l *Logger) Warn(msg string, keyvals ...interface{})  { l.log(LevelWarn, msg, keyvals) }
+func (l *Logger) Error(msg string, keyvals ...interface{}) { l.log(LevelError, msg, keyvals) }
+
+func (l *Logger) log(level Level, msg string, keyvals []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	record := make([]interface{}, 0, 6+len(l.fields)+len(keyvals))
+	record = append(record, "time", time.Now(), "level", level, "msg", msg)
+	record = append(record, l.fields...)
+	record = append(record, keyvals...)
+	if len(record)%2 != 0 {
+		// a key without a value is more likely a value without a key
+		record = append(record[:len(record)-1], "!BADKEY", record[len(record)-1])
+	}
+
+	var buf bytes.Buffer
+	if l.out.format == FormatJSON {
+		writeJSON(&buf, record)
+	} else {
+		writeLogfmt(&buf, record)
+	}
+	buf.WriteByte('\n')
+
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	_, _ = l.out.w.Write(buf.Bytes())
+}
+
+// text is v as a plain string, for logfmt and for JSON values that
+// don't marshal as anything more useful.
+func text(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "<nil>"
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func writeLogfmt(buf *bytes.Buffer, record []interface{}) {
+	for i := 0; i < len(record); i += 2 {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(logfmtKey(text(record[i])))
+		buf.WriteByte('=')
+		buf.WriteString(logfmtValue(text(record[i+1])))
+	}
+}
+
+// logfmtKey is key with anything that would break the key=value
+// pairs replaced.
+func logfmtKey(key string) string {
+	if key == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+// logfmtValue is value, quoted if it's empty or has spaces, quotes,
+// equals signs or control characters in it.
+func logfmtValue(value string) string {
+	if value == "" {
+		return `""`
+	}
+	for _, r := range value {
+		if r <= ' ' || r == '=' || r == '"' || r == 0x7f {
+			return strconv.Quote(value)
+		}
+	}
+	return value
+}
+
+func writeJSON(buf *bytes.Buffer, record []interface{}) {
+	buf.WriteByte('{')
+	for i := 0; i < len(record); i += 2 {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(text(record[i]))
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(jsonValue(record[i+1]))
+	}
+	buf.WriteByte('}')
+}
+
+// jsonValue is v as JSON. Numbers, bools, slices and the like are kept
+// as they are, and everything else is a string.
+func jsonValue(v interface{}) []byte {
+	switch v.(type) {
+	case nil, bool,
+		int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64,
+		float32, float64,
+		[]string, []int, map[string]string, map[string]int:
+		if value, err := json.Marshal(v); err == nil {
+			return value
+		}
+	}
+	value, _ := json.Marshal(text(v))
+	return value
+}
+
+type contextKey struct{}
+
+// NewContext is ctx carrying logger, so everything handling a request
+// logs with the same fields, like its request ID.
+func NewContext(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext is the Logger ctx carries, or Default.
+func FromContext(ctx context.Context) *Logger {
+	return FromContextOr(ctx, Default)
+}
+
+// FromContextOr is the Logger ctx carries, or fallback.
+func FromContextOr(ctx context.Context, fallback *Logger) *Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(contextKey{}).(*Logger); ok {
+			return logger
+		}
+	}
+	return fallback
+}
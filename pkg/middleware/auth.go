@@ -7,8 +7,10 @@ package middleware
 import (
 	"encoding/base64"
 	"net/http"
-	"os"
 	"time"
+
+	"github.com/StevenACoffman/teamboard/pkg/logging"
+	"github.com/StevenACoffman/teamboard/pkg/middleware/http2curl"
 )
 
 // HeaderRoundTripper is a client middleware for adding headers on every request.
@@ -70,7 +72,7 @@ func NewBasicAuthHTTPClient(user, token string) *http.Client {
 	header := make(http.Header)
 	header.Set("Content-Type", "application/json; charset=utf-8")
 	header.Set("Accept", "application/json; charset=utf-8")
-	rt := NewLoggingRoundTripper(http.DefaultTransport, os.Stdout)
+	rt := NewLoggingRoundTripper(http.DefaultTransport, logging.Default)
 	hrt := NewHeaderRoundTripper(rt, header)
 	hrt.BasicAuth(user, token)
 
@@ -87,7 +89,7 @@ func NewBearerAuthHTTPClient(token string) *http.Client {
 	header := make(http.Header)
 	header.Set("Content-Type", "application/json; charset=utf-8")
 	header.Set("Accept", "application/json; charset=utf-8")
-	rt := NewLoggingRoundTripper(http.DefaultTransport, os.Stdout)
+	rt := NewLoggingRoundTripper(http.DefaultTransport, logging.Default)
 	hrt := NewHeaderRoundTripper(rt, header)
 	hrt.BearerAuth(token)
 
@@ -96,3 +98,63 @@ func NewBearerAuthHTTPClient(token string) *http.Client {
 		Timeout:   60 * time.Second,
 	}
 }
+
+// ClientOptions tune the round trippers NewGitHubHTTPClient puts
+// between the client and GitHub.
+type ClientOptions struct {
+	// RateLimitReserve and RateLimitWait are for the RateLimitRoundTripper.
+	RateLimitReserve int
+	RateLimitWait    time.Duration
+	// Retries and RetryMaxElapsed are for the RetryRoundTripper.
+	// No Retries turns retrying off, and no RetryMaxElapsed is no limit.
+	Retries         int
+	RetryMaxElapsed time.Duration
+	// NoRedact logs credentials in failed requests, instead of hiding
+	// them. It's only for debugging locally.
+	NoRedact bool
+	// Logger is for the LoggingRoundTripper, for requests whose context
+	// doesn't carry one. Nil is logging.Default.
+	Logger *logging.Logger
+	// App authenticates as a GitHub App with an AppRoundTripper, instead
+	// of with the token.
+	App *AppAuth
+}
+
+// NewGitHubHTTPClient is NewBearerAuthHTTPClient, retrying transient
+// failures with a RetryRoundTripper, and keeping track of GitHub's rate
+// limit with a RateLimitRoundTripper, which is returned too so the budget
+// can be shown. Every retry goes through the rate limit, and gets logged.
+// With opts.App, it authenticates as the GitHub App instead of with token.
+func NewGitHubHTTPClient(token string, opts ClientOptions) (*http.Client, *RateLimitRoundTripper) {
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	header.Set("Accept", "application/json; charset=utf-8")
+	logger := opts.Logger
+	if logger == nil {
+		logger = logging.Default
+	}
+	rt := NewLoggingRoundTripper(http.DefaultTransport, logger)
+	if opts.NoRedact {
+		rt.Redaction = http2curl.NoRedaction
+	}
+	limiter := NewRateLimitRoundTripper(rt, opts.RateLimitReserve, opts.RateLimitWait)
+	retrier := NewRetryRoundTripper(limiter, opts.Retries, opts.RetryMaxElapsed)
+	if opts.App != nil {
+		// each installation has its own budget
+		limiter.PerOrg = true
+		// the app's own requests for tokens are logged, but not retried,
+		// and don't count against an installation's rate limit
+		app := NewAppRoundTripper(retrier, rt, *opts.App)
+		return &http.Client{
+			Transport: NewHeaderRoundTripper(app, header),
+			Timeout:   60 * time.Second,
+		}, limiter
+	}
+	hrt := NewHeaderRoundTripper(retrier, header)
+	hrt.BearerAuth(token)
+
+	return &http.Client{
+		Transport: hrt,
+		Timeout:   60 * time.Second,
+	}, limiter
+}
@@ -0,0 +1,259 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultGitHubAPI is where GitHub's REST API is, for the GitHub App
+// installation token exchange.
+const DefaultGitHubAPI = "https://api.github.com"
+
+// TokenRefreshBefore is how long before it expires an installation token
+// is replaced, so a request never goes out with a token that runs out on
+// the way.
+const TokenRefreshBefore = 5 * time.Minute
+
+// AppAuth is how to authenticate as a GitHub App, instead of with a
+// personal token.
+type AppAuth struct {
+	// AppID is the GitHub App's ID, from its settings page.
+	AppID int64
+	// PrivateKey is one of the app's private keys.
+	PrivateKey *rsa.PrivateKey
+	// DefaultOrg is the org whose installation is used for requests
+	// that aren't for any org in particular, like who am I.
+	DefaultOrg string
+}
+
+type orgKey struct{}
+
+// WithOrg returns a context for requests about org, so an AppRoundTripper
+// uses the app's installation in org for them.
+func WithOrg(ctx context.Context, org string) context.Context {
+	return context.WithValue(ctx, orgKey{}, org)
+}
+
+// OrgFrom is the org ctx came from WithOrg with, if any.
+func OrgFrom(ctx context.Context) string {
+	org, _ := ctx.Value(orgKey{}).(string)
+	return org
+}
+
+// installationToken is the token for one org's installation of the app.
+type installationToken struct {
+	mu      sync.Mutex
+	id      int64
+	token   string
+	expires time.Time
+}
+
+// AppRoundTripper is a client middleware that authenticates as a GitHub
+// App: it signs a JWT with the app's private key, exchanges it for an
+// installation token for the org the request is about (see WithOrg), and
+// adds that token to the request. Tokens are cached per org, and replaced
+// TokenRefreshBefore they expire, or when GitHub rejects one.
+type AppRoundTripper struct {
+	next http.RoundTripper
+	// api is for the app's own requests to GitHub, for tokens.
+	api  http.RoundTripper
+	Auth AppAuth
+	// BaseURL is GitHub's REST API, DefaultGitHubAPI unless it's changed.
+	BaseURL string
+
+	mu     sync.Mutex
+	tokens map[string]*installationToken
+}
+
+// NewAppRoundTripper authenticates requests to next with auth. The
+// requests for installation tokens go to api, or next if it's nil.
+func NewAppRoundTripper(next, api http.RoundTripper, auth AppAuth) *AppRoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if api == nil {
+		api = next
+	}
+	return &AppRoundTripper{
+		next:    next,
+		api:     api,
+		Auth:    auth,
+		BaseURL: DefaultGitHubAPI,
+		tokens:  make(map[string]*installationToken),
+	}
+}
+
+func (rt *AppRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	org := OrgFrom(req.Context())
+	if org == "" {
+		org = rt.Auth.DefaultOrg
+	}
+	if org == "" {
+		return nil, errors.New("GitHub App: no org to use the installation of")
+	}
+
+	token, err := rt.Token(req.Context(), org)
+	if err != nil {
+		return nil, err
+	}
+
+	// a RoundTripper mustn't change the request it was given, and the
+	// org is passed on, so the rate limit is counted against its budget
+	authed := req.Clone(WithOrg(req.Context(), org))
+	authed.Header.Set("Authorization", "token "+token)
+	resp, err := rt.next.RoundTrip(authed)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		// revoked, most likely, so the next request gets a fresh one
+		rt.forget(org)
+	}
+	return resp, err
+}
+
+// Token is an installation token for org, from the cache if it's good
+// for at least another TokenRefreshBefore.
+func (rt *AppRoundTripper) Token(ctx context.Context, org string) (string, error) {
+	rt.mu.Lock()
+	t, ok := rt.tokens[strings.ToLower(org)]
+	if !ok {
+		t = &installationToken{}
+		rt.tokens[strings.ToLower(org)] = t
+	}
+	rt.mu.Unlock()
+
+	// only one request per org waits for a new token, the rest use it
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" && time.Until(t.expires) > TokenRefreshBefore {
+		return t.token, nil
+	}
+
+	jwt, err := rt.jwt(time.Now())
+	if err != nil {
+		return "", err
+	}
+	if t.id == 0 {
+		var installation struct {
+			ID int64 `json:"id"`
+		}
+		err = rt.call(ctx, http.MethodGet, "/orgs/"+url.PathEscape(org)+"/installation", jwt, &installation)
+		if err != nil {
+			return "", fmt.Errorf("GitHub App installation for %s: %w", org, err)
+		}
+		t.id = installation.ID
+	}
+
+	var token struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	err = rt.call(ctx, http.MethodPost, fmt.Sprintf("/app/installations/%d/access_tokens", t.id), jwt, &token)
+	if err != nil {
+		return "", fmt.Errorf("GitHub App installation token for %s: %w", org, err)
+	}
+	t.token, t.expires = token.Token, token.ExpiresAt
+	return t.token, nil
+}
+
+// forget drops the cached token for org.
+func (rt *AppRoundTripper) forget(org string) {
+	rt.mu.Lock()
+	t, ok := rt.tokens[strings.ToLower(org)]
+	rt.mu.Unlock()
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.token = ""
+}
+
+// call makes a REST API request as the app, and decodes the response
+// into v.
+func (rt *AppRoundTripper) call(ctx context.Context, method, path, jwt string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(rt.BaseURL, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	resp, err := rt.api.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("the app isn't installed there: %s", resp.Status)
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return fmt.Errorf("%s: %s", resp.Status, body)
+	}
+	return json.Unmarshal(body, v)
+}
+
+// jwt is a JSON Web Token for the app, signed with its private key, which
+// GitHub accepts for up to ten minutes. It's backdated a minute in case
+// our clock is ahead of GitHub's.
+func (rt *AppRoundTripper) jwt(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]int64{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": rt.Auth.AppID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." +
+		base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, rt.Auth.PrivateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("signing GitHub App JWT: %w", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// ParsePrivateKey reads a GitHub App private key, as downloaded from the
+// app's settings page: a PEM RSA key, in either PKCS #1 or PKCS #8 form.
+func ParsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("GitHub App private key isn't PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("GitHub App private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("GitHub App private key isn't an RSA key")
+	}
+	return key, nil
+}
@@ -0,0 +1,318 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testAppID = 123456
+
+var (
+	testKeyOnce sync.Once
+	testKey     *rsa.PrivateKey
+)
+
+// testPrivateKey is an RSA key for the tests, made once since it's slow.
+func testPrivateKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	testKeyOnce.Do(func() {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testKey = key
+	})
+	return testKey
+}
+
+// fakeGitHub hands out installation tokens for any org, and answers
+// GraphQL requests with the token they were made with, or a 401 if it
+// has been revoked.
+type fakeGitHub struct {
+	*httptest.Server
+	t *testing.T
+	// expiresIn is how long the tokens it hands out are good for.
+	expiresIn time.Duration
+
+	mu            sync.Mutex
+	installations int
+	minted        []string
+	revoked       map[string]bool
+	jwts          []string
+}
+
+func newFakeGitHub(t *testing.T, expiresIn time.Duration) *fakeGitHub {
+	t.Helper()
+	gh := &fakeGitHub{t: t, expiresIn: expiresIn, revoked: make(map[string]bool)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orgs/", func(w http.ResponseWriter, r *http.Request) {
+		org := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/orgs/"), "/installation")
+		if !gh.appAuthorized(w, r) {
+			return
+		}
+		gh.mu.Lock()
+		gh.installations++
+		gh.mu.Unlock()
+		fmt.Fprintf(w, `{"id":%d}`, len(org))
+	})
+	mux.HandleFunc("/app/installations/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || !gh.appAuthorized(w, r) {
+			return
+		}
+		gh.mu.Lock()
+		token := fmt.Sprintf("ghs_%d", len(gh.minted)+1)
+		gh.minted = append(gh.minted, token)
+		gh.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"token":      token,
+			"expires_at": time.Now().Add(gh.expiresIn).UTC().Format(time.RFC3339),
+		})
+	})
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "token ")
+		gh.mu.Lock()
+		revoked := gh.revoked[token]
+		gh.mu.Unlock()
+		if revoked {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, token)
+	})
+	gh.Server = httptest.NewServer(mux)
+	t.Cleanup(gh.Close)
+	return gh
+}
+
+// appAuthorized checks the request was made as the app, with a JWT.
+func (gh *fakeGitHub) appAuthorized(w http.ResponseWriter, r *http.Request) bool {
+	jwt := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if jwt == r.Header.Get("Authorization") {
+		gh.t.Errorf("%s %s wasn't made with a JWT", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+	gh.mu.Lock()
+	gh.jwts = append(gh.jwts, jwt)
+	gh.mu.Unlock()
+	return true
+}
+
+func (gh *fakeGitHub) revoke(token string) {
+	gh.mu.Lock()
+	defer gh.mu.Unlock()
+	gh.revoked[token] = true
+}
+
+func (gh *fakeGitHub) counts() (installations, minted int) {
+	gh.mu.Lock()
+	defer gh.mu.Unlock()
+	return gh.installations, len(gh.minted)
+}
+
+func newTestAppRoundTripper(t *testing.T, gh *fakeGitHub) *AppRoundTripper {
+	rt := NewAppRoundTripper(nil, nil, AppAuth{
+		AppID:      testAppID,
+		PrivateKey: testPrivateKey(t),
+		DefaultOrg: "Khan",
+	})
+	rt.BaseURL = gh.URL
+	return rt
+}
+
+// tokenFor makes a GraphQL request about org, and returns the token it
+// was made with, or the status if it failed.
+func tokenFor(t *testing.T, rt http.RoundTripper, gh *fakeGitHub, org string) string {
+	t.Helper()
+	ctx := context.Background()
+	if org != "" {
+		ctx = WithOrg(ctx, org)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, gh.URL+"/graphql", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resp.Status
+	}
+	token, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(token)
+}
+
+func TestAppRoundTripperJWT(t *testing.T) {
+	key := testPrivateKey(t)
+	rt := NewAppRoundTripper(nil, nil, AppAuth{AppID: testAppID, PrivateKey: key})
+	now := time.Date(2021, 3, 8, 12, 0, 0, 0, time.UTC)
+
+	jwt, err := rt.jwt(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("JWT has %d parts, want 3: %s", len(parts), jwt)
+	}
+
+	var header map[string]string
+	decodePart(t, parts[0], &header)
+	if header["alg"] != "RS256" || header["typ"] != "JWT" {
+		t.Errorf("header = %v, want RS256 JWT", header)
+	}
+
+	var claims map[string]int64
+	decodePart(t, parts[1], &claims)
+	want := map[string]int64{
+		"iss": testAppID,
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+	}
+	for name, value := range want {
+		if claims[name] != value {
+			t.Errorf("claim %s = %d, want %d", name, claims[name], value)
+		}
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("signature doesn't verify with the app's public key: %v", err)
+	}
+}
+
+func decodePart(t *testing.T, part string, v interface{}) {
+	t.Helper()
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAppRoundTripperCachesTokens(t *testing.T) {
+	gh := newFakeGitHub(t, time.Hour)
+	rt := newTestAppRoundTripper(t, gh)
+
+	first := tokenFor(t, rt, gh, "Khan")
+	if again := tokenFor(t, rt, gh, "khan"); again != first {
+		t.Errorf("second request used %s, want the cached %s", again, first)
+	}
+	if other := tokenFor(t, rt, gh, "StevenACoffman"); other == first {
+		t.Errorf("another org used the same token, %s", other)
+	}
+	if fallback := tokenFor(t, rt, gh, ""); fallback != first {
+		t.Errorf("request without an org used %s, want the default org's %s", fallback, first)
+	}
+
+	if installations, minted := gh.counts(); installations != 2 || minted != 2 {
+		t.Errorf("looked up %d installations and minted %d tokens, want 2 of each", installations, minted)
+	}
+	if len(gh.jwts) == 0 {
+		t.Fatal("no JWTs were sent")
+	}
+	for _, jwt := range gh.jwts {
+		var claims map[string]int64
+		decodePart(t, strings.Split(jwt, ".")[1], &claims)
+		if claims["iss"] != testAppID {
+			t.Errorf("JWT was issued by %d, want %d", claims["iss"], testAppID)
+		}
+	}
+}
+
+func TestAppRoundTripperRefreshesBeforeExpiry(t *testing.T) {
+	// tokens that are already within TokenRefreshBefore of expiring
+	gh := newFakeGitHub(t, TokenRefreshBefore-time.Minute)
+	rt := newTestAppRoundTripper(t, gh)
+
+	first := tokenFor(t, rt, gh, "Khan")
+	if second := tokenFor(t, rt, gh, "Khan"); second == first {
+		t.Errorf("token %s was reused, though it expires within %s", first, TokenRefreshBefore)
+	}
+	// the installation is the same, only the token is new
+	if installations, minted := gh.counts(); installations != 1 || minted != 2 {
+		t.Errorf("looked up %d installations and minted %d tokens, want 1 and 2", installations, minted)
+	}
+}
+
+func TestAppRoundTripperForgetsRejectedTokens(t *testing.T) {
+	gh := newFakeGitHub(t, time.Hour)
+	rt := newTestAppRoundTripper(t, gh)
+
+	first := tokenFor(t, rt, gh, "Khan")
+	gh.revoke(first)
+	if got := tokenFor(t, rt, gh, "Khan"); got != "401 Unauthorized" {
+		t.Fatalf("request with the revoked token got %s, want 401 Unauthorized", got)
+	}
+	if got := tokenFor(t, rt, gh, "Khan"); got == first || strings.HasPrefix(got, "401") {
+		t.Errorf("request after the 401 got %s, want a new token", got)
+	}
+	if installations, minted := gh.counts(); installations != 1 || minted != 2 {
+		t.Errorf("looked up %d installations and minted %d tokens, want 1 and 2", installations, minted)
+	}
+}
+
+func TestAppRoundTripperNeedsAnOrg(t *testing.T) {
+	gh := newFakeGitHub(t, time.Hour)
+	rt := newTestAppRoundTripper(t, gh)
+	rt.Auth.DefaultOrg = ""
+
+	req, err := http.NewRequest(http.MethodPost, gh.URL+"/graphql", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := rt.RoundTrip(req); err == nil {
+		resp.Body.Close()
+		t.Error("a request about no org, without a default org, was made")
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	key := testPrivateKey(t)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, block := range map[string]*pem.Block{
+		"PKCS #1": {Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)},
+		"PKCS #8": {Type: "PRIVATE KEY", Bytes: pkcs8},
+	} {
+		parsed, err := ParsePrivateKey(pem.EncodeToMemory(block))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !parsed.Equal(key) {
+			t.Errorf("%s: parsed a different key", name)
+		}
+	}
+	if _, err := ParsePrivateKey([]byte("not a key")); err == nil {
+		t.Error("parsed a key that isn't PEM")
+	}
+}
@@ -2,8 +2,10 @@ package http2curl
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"sort"
 	"strings"
 )
@@ -25,8 +27,60 @@ func bashEscape(str string) string {
 	return `'` + strings.Replace(str, `'`, `'\''`, -1) + `'`
 }
 
-// GetCurlCommand returns a CurlCommand corresponding to an http.Request
+// GetCurlCommand returns a CurlCommand corresponding to an http.Request,
+// with credentials hidden by DefaultRedaction.
 func GetCurlCommand(req *http.Request) (*CurlCommand, error) {
+	return DefaultRedaction.CurlCommand(req)
+}
+
+// Redacted is what a hidden value is replaced with.
+const Redacted = "XXXXXX"
+
+// Redaction is what to hide when a request is turned into a command,
+// so credentials don't end up in logs.
+type Redaction struct {
+	// Headers are header names whose values are hidden, case insensitively.
+	Headers []string
+	// Fields are hidden wherever they appear in a JSON or form body, in
+	// the URL's query string, or as a header. A name matches if it contains
+	// any of them, case insensitively, so token hides access_token and
+	// refreshToken. Any of them also hides the password in the URL.
+	Fields []string
+}
+
+// DefaultRedaction hides auth headers, cookies, and token-like fields.
+var DefaultRedaction = Redaction{
+	Headers: []string{
+		"Authorization",
+		"authentication",
+		"Proxy-Authorization",
+		"X-Ka-Service-Admin-Query",
+		"X-Ka-Gateway-Secret",
+		"Cookie",
+		"Set-Cookie",
+		"X-Ka-Fkey",
+	},
+	Fields: []string{
+		"token",
+		"secret",
+		"password",
+		"passwd",
+		"private_key",
+		"privateKey",
+		"api_key",
+		"apiKey",
+		"credential",
+		"authorization",
+		"jwt",
+	},
+}
+
+// NoRedaction hides nothing. It's only for debugging locally.
+var NoRedaction = Redaction{}
+
+// CurlCommand returns a CurlCommand corresponding to an http.Request,
+// with what r says to hide hidden.
+func (r Redaction) CurlCommand(req *http.Request) (*CurlCommand, error) {
 	command := CurlCommand{}
 
 	command.append("curl")
@@ -44,7 +98,7 @@ func GetCurlCommand(req *http.Request) (*CurlCommand, error) {
 			return nil, fmt.Errorf("getCurlCommand: buffer read from body erorr: %w", err)
 		}
 		if len(buff.String()) > 0 {
-			bodyEscaped := bashEscape(buff.String())
+			bodyEscaped := bashEscape(r.Body(buff.String()))
 			command.append("-d", bodyEscaped)
 		}
 	}
@@ -58,28 +112,113 @@ func GetCurlCommand(req *http.Request) (*CurlCommand, error) {
 	sort.Strings(keys)
 
 	for _, k := range keys {
-		command.append("-H", appendHeader(req, k))
+		command.append("-H", r.appendHeader(req, k))
 	}
 
-	command.append(bashEscape(req.URL.String()))
+	command.append(bashEscape(r.URL(req.URL)))
 
 	return &command, nil
 }
 
-var redactedHeaders = []string{
-	"Authorization",
-	"authentication",
-	"X-Ka-Service-Admin-Query",
-	"X-Ka-Gateway-Secret",
-	"Cookie",
-	"X-Ka-Fkey",
+func (r Redaction) appendHeader(req *http.Request, k string) string {
+	if insensitiveContains(r.Headers, k) || r.field(k) {
+		return bashEscape(fmt.Sprintf("%s: %s", k, Redacted))
+	}
+	return bashEscape(fmt.Sprintf("%s: %s", k, strings.Join(req.Header[k], " ")))
+}
+
+// URL is u as a string, with its password, and the values of query
+// parameters that match Fields, hidden.
+func (r Redaction) URL(u *url.URL) string {
+	if len(r.Fields) == 0 {
+		return u.String()
+	}
+	hidden := *u
+	if _, ok := u.User.Password(); ok {
+		hidden.User = url.UserPassword(u.User.Username(), Redacted)
+	}
+	if q := u.Query(); r.values(q) {
+		hidden.RawQuery = q.Encode()
+	}
+	return hidden.String()
 }
 
-func appendHeader(req *http.Request, k string) string {
-	if insensitiveContains(redactedHeaders, k) {
-		return bashEscape(fmt.Sprintf("%s: %s", k, "XXXXXX"))
+// Body is body with the values of JSON fields that match Fields hidden,
+// however deeply they're nested, or those of form fields, if it's a form.
+// Any other body is left alone.
+func (r Redaction) Body(body string) string {
+	if len(r.Fields) == 0 {
+		return body
 	}
-	return bashEscape(fmt.Sprintf("%s: %s", k, strings.Join(req.Header[k], " ")))
+	var v interface{}
+	if json.Unmarshal([]byte(body), &v) != nil {
+		if form, err := url.ParseQuery(body); err == nil && r.values(form) {
+			return form.Encode()
+		}
+		return body
+	}
+	v, redacted := r.value(v)
+	if !redacted {
+		return body
+	}
+
+	var buff bytes.Buffer
+	encoder := json.NewEncoder(&buff)
+	encoder.SetEscapeHTML(false)
+	if encoder.Encode(v) != nil {
+		return body
+	}
+	return strings.TrimSuffix(buff.String(), "\n")
+}
+
+// values hides the values in q whose names match Fields, and reports
+// whether there were any.
+func (r Redaction) values(q url.Values) bool {
+	redacted := false
+	for name := range q {
+		if r.field(name) {
+			q.Set(name, Redacted)
+			redacted = true
+		}
+	}
+	return redacted
+}
+
+// value hides the fields of v that match Fields, and reports whether
+// there were any.
+func (r Redaction) value(v interface{}) (interface{}, bool) {
+	redacted := false
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for name, field := range v {
+			if r.field(name) {
+				v[name] = Redacted
+				redacted = true
+				continue
+			}
+			var ok bool
+			v[name], ok = r.value(field)
+			redacted = redacted || ok
+		}
+	case []interface{}:
+		for i, item := range v {
+			var ok bool
+			v[i], ok = r.value(item)
+			redacted = redacted || ok
+		}
+	}
+	return v, redacted
+}
+
+// field reports whether name matches any of Fields.
+func (r Redaction) field(name string) bool {
+	name = strings.ToLower(name)
+	for _, field := range r.Fields {
+		if strings.Contains(name, strings.ToLower(field)) {
+			return true
+		}
+	}
+	return false
 }
 
 // insensitiveContains checks if a string slice contains an element, case
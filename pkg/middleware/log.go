@@ -3,29 +3,37 @@ package middleware
 import (
 	"bytes"
 	"encoding/json"
-	"fmt"
-	"io"
 	"io/ioutil"
 	"net/http"
 	"time"
 
 	"github.com/vektah/gqlparser/v2/gqlerror"
 
+	"github.com/StevenACoffman/teamboard/pkg/logging"
 	"github.com/StevenACoffman/teamboard/pkg/middleware/http2curl"
 )
 
+// LoggingRoundTripper is a client middleware that logs every request at
+// debug level, with its GraphQL operation name, status code and duration,
+// and requests that failed as a warning, along with a curl command to make
+// them again and the response. Credentials are hidden by Redaction, which
+// is http2curl.DefaultRedaction unless it's changed. It logs with the
+// request context's logger, if it has one, so records carry the ID of the
+// page request they were made for, or else with the one it was made with.
 type LoggingRoundTripper struct {
-	next   http.RoundTripper
-	logger io.Writer
+	next      http.RoundTripper
+	logger    *logging.Logger
+	Redaction http2curl.Redaction
 }
 
 func NewLoggingRoundTripper(
 	next http.RoundTripper,
-	w io.Writer,
+	logger *logging.Logger,
 ) *LoggingRoundTripper {
 	return &LoggingRoundTripper{
-		next:   next,
-		logger: w,
+		next:      next,
+		logger:    logger,
+		Redaction: http2curl.DefaultRedaction,
 	}
 }
 
@@ -33,42 +41,65 @@ func (rt *LoggingRoundTripper) RoundTrip(
 	req *http.Request,
 ) (resp *http.Response, err error) {
 	defer func(begin time.Time) {
-		var msg string
+		logger := logging.FromContextOr(req.Context(), rt.logger).With(
+			"operation", operationName(req),
+			"method", req.Method,
+			"url", rt.Redaction.URL(req.URL),
+			"duration", time.Since(begin),
+		)
+		if err != nil {
+			logger.Warn("github request failed", "error", err)
+			return
+		}
+
 		body, getResponseBodyErr := GetResponseBody(resp)
 		if getResponseBodyErr != nil {
-			fmt.Println("unable to get response Body", getResponseBodyErr)
+			logger.Warn("unable to get response body", "status", resp.StatusCode, "error", getResponseBodyErr)
 		}
-		gotHTTPErr := resp != nil && (resp.StatusCode < 200 || resp.StatusCode >= 300)
+		gotHTTPErr := resp.StatusCode < 200 || resp.StatusCode >= 300
 		graphqlErr := GetGraphQLErrors(resp)
 		if !gotHTTPErr && graphqlErr == nil {
-			// only log when there was a problem
+			logger.Debug("github request", "status", resp.StatusCode)
 			return
 		}
-		msg = fmt.Sprintf(
-			"method=%s host=%s path=%s status_code=%d took=%s\n",
-			req.Method,
-			req.URL.Host,
-			req.URL.Path,
-			resp.StatusCode,
-			time.Since(begin),
-		)
-		if err != nil {
-			fmt.Fprintf(rt.logger, "%s : %+v\n", msg, err)
-		} else {
-			fmt.Fprintf(rt.logger, "%s\n", msg)
-		}
-		command, _ := http2curl.GetCurlCommand(req)
-		fmt.Println(command)
 
-		fmt.Println("body:\n", body)
+		keyvals := []interface{}{
+			"status", resp.StatusCode,
+			"body", rt.Redaction.Body(body),
+		}
+		if command, err := rt.Redaction.CurlCommand(req); err == nil {
+			keyvals = append(keyvals, "curl", command)
+		}
 		if graphqlErr != nil {
-			fmt.Printf("graphql errors:%+v\n", graphqlErr)
+			keyvals = append(keyvals, "graphql_errors", graphqlErr)
 		}
+		logger.Warn("github request failed", keyvals...)
 	}(time.Now())
 
 	return rt.next.RoundTrip(req)
 }
 
+// operationName is the operationName of a GraphQL request, or empty if
+// req isn't one.
+func operationName(req *http.Request) string {
+	if req.GetBody == nil {
+		return ""
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer body.Close()
+
+	var gql struct {
+		OperationName string `json:"operationName"`
+	}
+	if json.NewDecoder(body).Decode(&gql) != nil {
+		return ""
+	}
+	return gql.OperationName
+}
+
 // GetResponseBody will read the response body without clobbering it
 // so it can be re-read elsewhere
 func GetResponseBody(r *http.Response) (string, error) {
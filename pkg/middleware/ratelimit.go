@@ -0,0 +1,251 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/StevenACoffman/teamboard/pkg/logging"
+)
+
+// RateLimit is GitHub's API budget, as of the last response.
+type RateLimit struct {
+	// Limit is the most points that can be used in an hour.
+	Limit int `json:"limit"`
+	// Remaining is how many points are left until Reset.
+	Remaining int `json:"remaining"`
+	// Used is how many points have been used since the last reset.
+	Used int `json:"used"`
+	// Reset is when Remaining goes back up to Limit.
+	Reset time.Time `json:"resetAt"`
+	// Cost is how many points a GraphQL query cost, in the rateLimit
+	// object it asked for. It isn't kept with the budget, see Cost.
+	Cost int `json:"cost"`
+	// Updated is when the budget was last heard from GitHub.
+	Updated time.Time `json:"updated"`
+}
+
+// Known reports whether GitHub has said what the budget is yet.
+func (r RateLimit) Known() bool {
+	return !r.Updated.IsZero()
+}
+
+// Cost adds up how many points of the budget the requests made for
+// something, like a page, took. A nil *Cost adds nothing up.
+type Cost struct {
+	mu     sync.Mutex
+	points int
+}
+
+// Points are how many points have been added up so far.
+func (c *Cost) Points() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.points
+}
+
+func (c *Cost) add(points int) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.points += points
+}
+
+type costKey struct{}
+
+// WithCost returns a context that adds up the cost of the requests made
+// with it, so far as a RateLimitRoundTripper sees them, in the Cost.
+func WithCost(ctx context.Context) (context.Context, *Cost) {
+	cost := &Cost{}
+	return context.WithValue(ctx, costKey{}, cost), cost
+}
+
+// CostFrom is the Cost ctx came from WithCost with, if any.
+func CostFrom(ctx context.Context) *Cost {
+	cost, _ := ctx.Value(costKey{}).(*Cost)
+	return cost
+}
+
+// RateLimitError is returned instead of making a request, when there is
+// too little budget left and it resets too far in the future to wait for.
+type RateLimitError struct {
+	RateLimit RateLimit
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("GitHub rate limit: %d of %d points left until %s",
+		e.RateLimit.Remaining, e.RateLimit.Limit, e.RateLimit.Reset.Format(time.Kitchen))
+}
+
+// RateLimitRoundTripper is a client middleware that keeps track of GitHub's
+// rate limit, from the X-RateLimit-* headers of every response, and the
+// rateLimit object of GraphQL responses that ask for it. Once Reserve or
+// fewer points are left, requests wait for the reset, if it's within
+// MaxWait, or else fail with a RateLimitError, so there is some budget left
+// for anything else using the same token.
+//
+// With PerOrg, each org has a budget of its own, as each installation of a
+// GitHub App does, and requests only wait for the budget of the org they
+// are about (see WithOrg).
+type RateLimitRoundTripper struct {
+	next    http.RoundTripper
+	Reserve int
+	MaxWait time.Duration
+	PerOrg  bool
+
+	mu     sync.Mutex
+	limits map[string]RateLimit
+}
+
+func NewRateLimitRoundTripper(
+	next http.RoundTripper,
+	reserve int,
+	maxWait time.Duration,
+) *RateLimitRoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &RateLimitRoundTripper{
+		next:    next,
+		Reserve: reserve,
+		MaxWait: maxWait,
+		limits:  make(map[string]RateLimit),
+	}
+}
+
+// RateLimit is the budget as of the last response. With PerOrg, it's
+// the budget of whichever org has the fewest points left.
+func (rt *RateLimitRoundTripper) RateLimit() RateLimit {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	var lowest RateLimit
+	for _, limit := range rt.limits {
+		if !lowest.Known() || limit.Remaining < lowest.Remaining {
+			lowest = limit
+		}
+	}
+	return lowest
+}
+
+// budget is the key of the budget req counts against.
+func (rt *RateLimitRoundTripper) budget(req *http.Request) string {
+	if !rt.PerOrg {
+		return ""
+	}
+	return strings.ToLower(OrgFrom(req.Context()))
+}
+
+func (rt *RateLimitRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := rt.wait(req); err != nil {
+		return nil, err
+	}
+
+	resp, err := rt.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	rt.update(req, resp)
+	return resp, nil
+}
+
+// wait holds req back until the reset, if the budget is down to the
+// reserve.
+func (rt *RateLimitRoundTripper) wait(req *http.Request) error {
+	rt.mu.Lock()
+	limit := rt.limits[rt.budget(req)]
+	rt.mu.Unlock()
+	if !limit.Known() || limit.Remaining > rt.Reserve {
+		return nil
+	}
+	wait := time.Until(limit.Reset)
+	if wait <= 0 {
+		return nil
+	}
+	if wait > rt.MaxWait {
+		return &RateLimitError{RateLimit: limit}
+	}
+
+	logging.FromContext(req.Context()).Info("waiting for rate limit reset",
+		"org", OrgFrom(req.Context()),
+		"remaining", limit.Remaining,
+		"wait", wait,
+	)
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+// update records the budget req counted against from the headers of resp,
+// and adds what req cost to the Cost of its context: what the rateLimit
+// object in the body says, or else a point, the least a query costs.
+func (rt *RateLimitRoundTripper) update(req *http.Request, resp *http.Response) {
+	limit, ok := headerRateLimit(resp.Header)
+	if !ok {
+		return
+	}
+	cost, ok := graphqlCost(resp)
+	if !ok {
+		cost = 1
+	}
+	CostFrom(req.Context()).add(cost)
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.limits[rt.budget(req)] = limit
+}
+
+// headerRateLimit reads the budget out of the X-RateLimit-* headers.
+func headerRateLimit(header http.Header) (RateLimit, bool) {
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return RateLimit{}, false
+	}
+	limit := RateLimit{Remaining: remaining, Updated: time.Now()}
+	limit.Limit, _ = strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	limit.Used, _ = strconv.Atoi(header.Get("X-RateLimit-Used"))
+	if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		limit.Reset = time.Unix(reset, 0)
+	}
+	return limit, true
+}
+
+// graphqlCost reads the cost out of the rateLimit object in a GraphQL
+// response, without clobbering the body.
+func graphqlCost(resp *http.Response) (int, bool) {
+	if resp.Body == nil {
+		return 0, false
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+	if err != nil {
+		return 0, false
+	}
+
+	var data struct {
+		Data struct {
+			RateLimit *RateLimit `json:"rateLimit"`
+		} `json:"data"`
+	}
+	if json.Unmarshal(body, &data) != nil || data.Data.RateLimit == nil {
+		return 0, false
+	}
+	return data.Data.RateLimit.Cost, true
+}
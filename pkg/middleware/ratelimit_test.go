@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimitRoundTripperPerOrg(t *testing.T) {
+	// Khan's budget is spent until long after MaxWait, Khan-Academy's isn't
+	reset := time.Now().Add(time.Hour).Unix()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remaining := 5000
+		if r.URL.Path == "/Khan" {
+			remaining = 0
+		}
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", fmt.Sprint(remaining))
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprint(reset))
+	}))
+	t.Cleanup(server.Close)
+
+	for _, perOrg := range []bool{true, false} {
+		t.Run(fmt.Sprintf("PerOrg %t", perOrg), func(t *testing.T) {
+			rt := NewRateLimitRoundTripper(nil, 100, time.Second)
+			rt.PerOrg = perOrg
+			get := func(org string) error {
+				req, err := http.NewRequestWithContext(WithOrg(context.Background(), org), http.MethodGet, server.URL+"/"+org, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp, err := rt.RoundTrip(req)
+				if err == nil {
+					resp.Body.Close()
+				}
+				return err
+			}
+
+			if err := get("Khan"); err != nil {
+				t.Fatal(err)
+			}
+			var limitErr *RateLimitError
+			err := get("Khan-Academy")
+			if perOrg && err != nil {
+				t.Errorf("Khan-Academy was held up by Khan's budget: %v", err)
+			}
+			if !perOrg && !errors.As(err, &limitErr) {
+				t.Errorf("got %v, want a RateLimitError for the shared budget", err)
+			}
+			if err := get("Khan"); !errors.As(err, &limitErr) {
+				t.Errorf("got %v, want a RateLimitError for Khan", err)
+			}
+			if got := rt.RateLimit().Remaining; got != 0 {
+				t.Errorf("RateLimit().Remaining = %d, want the lowest budget, 0", got)
+			}
+		})
+	}
+}
+
+// closeTracker is a response body that remembers being closed.
+type closeTracker struct {
+	io.Reader
+	closed bool
+}
+
+func (b *closeTracker) Close() error {
+	b.closed = true
+	return nil
+}
+
+// roundTripFunc answers requests without a server, so the response
+// bodies can be tracked.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRateLimitRoundTripperCost(t *testing.T) {
+	bodies := []string{
+		`{"data":{"rateLimit":{"cost":3,"remaining":4990},"search":{}}}`,
+		`{"data":{"search":{}}}`,
+		`{"data":{"rateLimit":{"cost":2,"remaining":4987},"search":{}}}`,
+	}
+	var sent []*closeTracker
+	next := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body := &closeTracker{Reader: strings.NewReader(bodies[len(sent)])}
+		sent = append(sent, body)
+		header := make(http.Header)
+		header.Set("X-RateLimit-Remaining", "4990")
+		return &http.Response{StatusCode: http.StatusOK, Header: header, Body: body}, nil
+	})
+	rt := NewRateLimitRoundTripper(next, 100, time.Second)
+
+	ctx, cost := WithCost(context.Background())
+	for i := range bodies {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.github.com/graphql", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || string(body) != bodies[i] {
+			t.Errorf("response %d body = %q, %v, want %q", i+1, body, err, bodies[i])
+		}
+		if !sent[i].closed {
+			t.Errorf("response %d: the original body wasn't closed", i+1)
+		}
+	}
+
+	// the query that didn't say what it cost counts as a point
+	if got := cost.Points(); got != 6 {
+		t.Errorf("Points() = %d, want 6", got)
+	}
+}
@@ -0,0 +1,235 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/StevenACoffman/teamboard/pkg/logging"
+)
+
+// DefaultRetryBaseDelay and DefaultRetryMaxDelay bound the backoff
+// between retries: the first waits up to the base delay, and each one
+// after that up to twice as long as the last, but never more than the max.
+const (
+	DefaultRetryBaseDelay = 250 * time.Millisecond
+	DefaultRetryMaxDelay  = 5 * time.Second
+)
+
+// RetryRoundTripper is a client middleware that retries requests that
+// failed in a way that might well work next time: a network error, a 502,
+// 503 or 504, a secondary rate limit 403 or 429 with Retry-After, or a
+// GraphQL "Something went wrong" error. It waits with exponential backoff
+// and full jitter in between, or as long as Retry-After says.
+//
+// Only idempotent requests are retried: those with an idempotent method,
+// or GraphQL POSTs that are queries rather than mutations. It gives up after
+// MaxRetries, or once MaxElapsed has passed, or the request's context is
+// done, whichever comes first, and returns the last response or error.
+// A MaxElapsed of zero is no limit, other than the context's.
+type RetryRoundTripper struct {
+	next       http.RoundTripper
+	MaxRetries int
+	MaxElapsed time.Duration
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+func NewRetryRoundTripper(
+	next http.RoundTripper,
+	maxRetries int,
+	maxElapsed time.Duration,
+) *RetryRoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &RetryRoundTripper{
+		next:       next,
+		MaxRetries: maxRetries,
+		MaxElapsed: maxElapsed,
+		BaseDelay:  DefaultRetryBaseDelay,
+		MaxDelay:   DefaultRetryMaxDelay,
+	}
+}
+
+func (rt *RetryRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt.MaxRetries <= 0 || !idempotent(req) {
+		return rt.next.RoundTrip(req)
+	}
+
+	// a zero deadline is no limit
+	var deadline time.Time
+	if rt.MaxElapsed > 0 {
+		deadline = time.Now().Add(rt.MaxElapsed)
+	}
+	if d, ok := req.Context().Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := rt.next.RoundTrip(req)
+		retry, after := shouldRetry(req, resp, err)
+		if !retry || attempt >= rt.MaxRetries || req.Context().Err() != nil {
+			return resp, err
+		}
+
+		delay := after
+		if delay == 0 {
+			delay = rt.backoff(attempt)
+		}
+		if !deadline.IsZero() && time.Now().Add(delay).After(deadline) {
+			return resp, err
+		}
+		logger := logging.FromContext(req.Context()).With(
+			"attempt", attempt+1,
+			"delay", delay,
+		)
+		if err != nil {
+			logger.Info("retrying github request", "error", err)
+		} else {
+			logger.Info("retrying github request", "status", resp.StatusCode)
+		}
+		if resp != nil {
+			// let the connection be reused
+			_, _ = ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// backoff is how long to wait before retry attempt+1: a random time up
+// to BaseDelay * 2^attempt, capped at MaxDelay.
+func (rt *RetryRoundTripper) backoff(attempt int) time.Duration {
+	ceiling := rt.MaxDelay
+	if attempt < 30 {
+		if d := rt.BaseDelay << uint(attempt); d > 0 && d < ceiling {
+			ceiling = d
+		}
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// idempotent reports whether req can safely be made more than once.
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	case http.MethodPost:
+		if req.Header.Get("Idempotency-Key") != "" {
+			return true
+		}
+		return req.GetBody != nil && graphqlQuery(req)
+	default:
+		return false
+	}
+}
+
+// graphqlQuery reports whether req is a GraphQL query, as opposed to a
+// mutation, which mustn't be made twice.
+func graphqlQuery(req *http.Request) bool {
+	body, err := req.GetBody()
+	if err != nil {
+		return false
+	}
+	defer body.Close()
+
+	var gql struct {
+		Query string `json:"query"`
+	}
+	if json.NewDecoder(body).Decode(&gql) != nil {
+		return false
+	}
+	query := strings.TrimSpace(gql.Query)
+	return strings.HasPrefix(query, "query") || strings.HasPrefix(query, "{")
+}
+
+// shouldRetry reports whether the request should be tried again, and how
+// long GitHub asked to wait first, if it did.
+func shouldRetry(req *http.Request, resp *http.Response, err error) (bool, time.Duration) {
+	if err != nil {
+		// our own limits aren't going to change by asking again
+		var rateErr *RateLimitError
+		if errors.As(err, &rateErr) || req.Context().Err() != nil {
+			return false, 0
+		}
+		return true, 0
+	}
+
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true, retryAfter(resp)
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		// only secondary rate limits say how long to wait
+		after := retryAfter(resp)
+		return after > 0, after
+	case http.StatusOK:
+		return somethingWentWrong(resp), 0
+	default:
+		return false, 0
+	}
+}
+
+// retryAfter is how long the Retry-After header says to wait, in seconds
+// or as a date, or zero if there isn't one.
+func retryAfter(resp *http.Response) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		if d := time.Until(when); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// somethingWentWrong reports whether resp has GitHub's GraphQL error for
+// a query that timed out or otherwise failed on its side.
+func somethingWentWrong(resp *http.Response) bool {
+	if resp.Body == nil {
+		return false
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+	if err != nil {
+		return false
+	}
+
+	var dataAndErrors response
+	if json.Unmarshal(body, &dataAndErrors) != nil {
+		return false
+	}
+	for _, gqlErr := range dataAndErrors.Errors {
+		if strings.HasPrefix(gqlErr.Message, "Something went wrong") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,241 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	graphqlQueryBody    = `{"query":"query MyLogin { viewer { login } }","operationName":"MyLogin"}`
+	graphqlMutationBody = `{"query":"mutation AddComment { addComment(input: {}) { clientMutationId } }"}`
+)
+
+// scriptedServer answers each request with the next of its responses,
+// repeating the last one, and remembers the bodies it was sent.
+type scriptedServer struct {
+	*httptest.Server
+	responses []func(w http.ResponseWriter)
+
+	mu     sync.Mutex
+	bodies []string
+}
+
+func newScriptedServer(t *testing.T, responses ...func(w http.ResponseWriter)) *scriptedServer {
+	t.Helper()
+	s := &scriptedServer{responses: responses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		s.mu.Lock()
+		s.bodies = append(s.bodies, string(body))
+		i := len(s.bodies) - 1
+		s.mu.Unlock()
+		if i >= len(s.responses) {
+			i = len(s.responses) - 1
+		}
+		s.responses[i](w)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// attempts is how many requests the server got.
+func (s *scriptedServer) attempts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.bodies)
+}
+
+func status(code int) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.WriteHeader(code)
+	}
+}
+
+func statusRetryAfter(code int, seconds int) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.Header().Set("Retry-After", fmt.Sprint(seconds))
+		w.WriteHeader(code)
+	}
+}
+
+func graphqlBody(body string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	}
+}
+
+var success = graphqlBody(`{"data":{"viewer":{"login":"octocat"}}}`)
+
+// newTestRetryRoundTripper retries without waiting long, so the tests
+// are quick.
+func newTestRetryRoundTripper(maxRetries int, maxElapsed time.Duration) *RetryRoundTripper {
+	rt := NewRetryRoundTripper(nil, maxRetries, maxElapsed)
+	rt.BaseDelay = time.Millisecond
+	rt.MaxDelay = 5 * time.Millisecond
+	return rt
+}
+
+func post(t *testing.T, ctx context.Context, rt http.RoundTripper, url, body string) (*http.Response, error) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return (&http.Client{Transport: rt}).Do(req)
+}
+
+func TestRetryRoundTripper(t *testing.T) {
+	tests := []struct {
+		name         string
+		responses    []func(w http.ResponseWriter)
+		body         string
+		wantStatus   int
+		wantAttempts int
+	}{
+		{
+			name:         "502 and 503 then success",
+			responses:    []func(w http.ResponseWriter){status(502), status(503), success},
+			body:         graphqlQueryBody,
+			wantStatus:   200,
+			wantAttempts: 3,
+		},
+		{
+			name:         "gives up after MaxRetries",
+			responses:    []func(w http.ResponseWriter){status(503)},
+			body:         graphqlQueryBody,
+			wantStatus:   503,
+			wantAttempts: 4,
+		},
+		{
+			name:         "403 with Retry-After",
+			responses:    []func(w http.ResponseWriter){statusRetryAfter(403, 1), success},
+			body:         graphqlQueryBody,
+			wantStatus:   200,
+			wantAttempts: 2,
+		},
+		{
+			name:         "403 without Retry-After",
+			responses:    []func(w http.ResponseWriter){status(403), success},
+			body:         graphqlQueryBody,
+			wantStatus:   403,
+			wantAttempts: 1,
+		},
+		{
+			name: "200 with Something went wrong",
+			responses: []func(w http.ResponseWriter){
+				graphqlBody(`{"errors":[{"message":"Something went wrong while executing your query. Please try again."}]}`),
+				success,
+			},
+			body:         graphqlQueryBody,
+			wantStatus:   200,
+			wantAttempts: 2,
+		},
+		{
+			name:         "mutation is never retried",
+			responses:    []func(w http.ResponseWriter){status(503), success},
+			body:         graphqlMutationBody,
+			wantStatus:   503,
+			wantAttempts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newScriptedServer(t, tt.responses...)
+			resp, err := post(t, context.Background(), newTestRetryRoundTripper(3, time.Minute), server.URL, tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := server.attempts(); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryRoundTripperResendsBody(t *testing.T) {
+	server := newScriptedServer(t, status(502), status(504), success)
+	resp, err := post(t, context.Background(), newTestRetryRoundTripper(3, time.Minute), server.URL, graphqlQueryBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if server.attempts() != 3 {
+		t.Fatalf("attempts = %d, want 3", server.attempts())
+	}
+	for i, body := range server.bodies {
+		if body != graphqlQueryBody {
+			t.Errorf("attempt %d sent %q, want %q", i+1, body, graphqlQueryBody)
+		}
+	}
+}
+
+func TestRetryRoundTripperGivesUp(t *testing.T) {
+	// GitHub asks for a second, which is longer than the test allows
+	waitASecond := statusRetryAfter(503, 1)
+
+	t.Run("at MaxElapsed", func(t *testing.T) {
+		server := newScriptedServer(t, waitASecond, success)
+		start := time.Now()
+		resp, err := post(t, context.Background(), newTestRetryRoundTripper(3, 100*time.Millisecond), server.URL, graphqlQueryBody)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != 503 || server.attempts() != 1 {
+			t.Errorf("got %d after %d attempts, want 503 after 1", resp.StatusCode, server.attempts())
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("took %s, want it to give up without waiting", elapsed)
+		}
+	})
+
+	t.Run("at the context deadline", func(t *testing.T) {
+		server := newScriptedServer(t, waitASecond, success)
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		resp, err := post(t, ctx, newTestRetryRoundTripper(3, time.Minute), server.URL, graphqlQueryBody)
+		if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatal(err)
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		if server.attempts() != 1 {
+			t.Errorf("attempts = %d, want 1", server.attempts())
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("took %s, want it to give up without waiting", elapsed)
+		}
+	})
+
+	t.Run("not when MaxElapsed is zero", func(t *testing.T) {
+		server := newScriptedServer(t, status(503), status(503), success)
+		resp, err := post(t, context.Background(), newTestRetryRoundTripper(3, 0), server.URL, graphqlQueryBody)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != 200 || server.attempts() != 3 {
+			t.Errorf("got %d after %d attempts, want 200 after 3", resp.StatusCode, server.attempts())
+		}
+	})
+}
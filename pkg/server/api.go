@@ -0,0 +1,138 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"github.com/StevenACoffman/teamboard/pkg/github"
+)
+
+// apiError is the JSON body of every error from the /api/ endpoints.
+type apiError struct {
+	Status  int           `json:"status"`
+	Message string        `json:"message"`
+	Error   string        `json:"error"`
+	Details gqlerror.List `json:"details,omitempty"`
+}
+
+// APIOrgs responds with the logins of the orgs you belong to, or for a
+// GitHub App, the configured ones.
+func (s *ServerHandler) APIOrgs(w http.ResponseWriter, req *http.Request) {
+	myOrgs, err := s.myOrgs(req.Context())
+	if err != nil {
+		s.writeJSONError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, myOrgs)
+}
+
+// APITeams responds with the slugs of the teams you belong to in ?org=,
+// or every team in it, for a GitHub App without a configured login.
+func (s *ServerHandler) APITeams(w http.ResponseWriter, req *http.Request) {
+	org := req.URL.Query().Get("org")
+	if org == "" {
+		s.writeJSONError(w, missingParam("org"))
+		return
+	}
+
+	myLogin, err := s.myLogin(req.Context())
+	if err != nil {
+		s.writeJSONError(w, err)
+		return
+	}
+	myTeams, err := github.GetTeams(req.Context(), s.graphqlClient, myLogin, org)
+	if err != nil {
+		s.writeJSONError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, myTeams)
+}
+
+// APIPulls responds with the same pull requests as the board for
+// ?org= and ?team=, most recent first, each with the reasons it is there.
+// With more than one ?org= the team is optional, as it is for the board.
+// They can be sorted and filtered with the same query parameters as the
+// board, see view.
+func (s *ServerHandler) APIPulls(w http.ResponseWriter, req *http.Request) {
+	orgs, team := s.orgsAndTeam(req)
+	if len(orgs) == 0 {
+		s.writeJSONError(w, missingParam("org"))
+		return
+	}
+	if team == "" && len(orgs) == 1 {
+		s.writeJSONError(w, missingParam("team"))
+		return
+	}
+
+	v, err := parseView(req)
+	if err != nil {
+		s.writeJSONError(w, err)
+		return
+	}
+
+	myLogin, err := s.myLogin(req.Context())
+	if err != nil {
+		s.writeJSONError(w, err)
+		return
+	}
+	board, err := s.getBoard(req, myLogin, orgs, team, s.config.SearchOptions())
+	if err != nil {
+		s.writeJSONError(w, err)
+		return
+	}
+
+	pulls := v.Filter.Pulls(board.Pulls())
+	sort.SliceStable(pulls, func(i, j int) bool {
+		// results in most recent to oldest
+		return pulls[i].CreatedAt.After(pulls[j].CreatedAt)
+	})
+	v.Sort.Pulls(pulls)
+	s.writeJSON(w, http.StatusOK, pulls)
+}
+
+func missingParam(name string) error {
+	return &httpError{
+		Status:  http.StatusBadRequest,
+		Message: fmt.Sprintf("The %s query parameter is required.", name),
+		Err:     fmt.Errorf("missing query parameter %q", name),
+	}
+}
+
+// writeJSON writes v out as the JSON response body.
+func (s *ServerHandler) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		s.log(w).Error("unable to marshal JSON", "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if _, err := w.Write(body); err != nil {
+		// the client most likely went away, and it's too late to tell it
+		s.log(w).Warn("error writing response", "error", err)
+	}
+}
+
+// writeJSONError is renderError for the /api/ endpoints.
+func (s *ServerHandler) writeJSONError(w http.ResponseWriter, err error) {
+	httpErr := newHTTPError(err, s.config.AppAuth() != nil)
+	s.logError(w, httpErr)
+
+	body := apiError{
+		Status:  httpErr.Status,
+		Message: httpErr.Message,
+		Error:   httpErr.Err.Error(),
+	}
+	var list gqlerror.List
+	if errors.As(err, &list) {
+		body.Details = list
+	}
+	s.writeJSON(w, httpErr.Status, body)
+}
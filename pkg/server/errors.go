@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"github.com/StevenACoffman/teamboard/pkg/github"
+	"github.com/StevenACoffman/teamboard/pkg/middleware"
+)
+
+// httpError is an error along with the HTTP status it should be
+// reported to the browser with, and a message a person can act on.
+type httpError struct {
+	Status  int
+	Message string
+	Err     error
+}
+
+func (e *httpError) Error() string {
+	return fmt.Sprintf("%d %s: %v", e.Status, e.Message, e.Err)
+}
+
+func (e *httpError) Unwrap() error {
+	return e.Err
+}
+
+// errorPage is the data for the error template.
+type errorPage struct {
+	Status     int
+	StatusText string
+	Message    string
+	Err        string
+	// Details are the GraphQL errors GitHub sent back, if any.
+	Details gqlerror.List
+}
+
+// newHTTPError works out which HTTP status best describes an error
+// from the github package, or from the GitHub API underneath it. app is
+// whether teamboard authenticates as a GitHub App, rather than with a token.
+func newHTTPError(err error, app bool) *httpError {
+	var httpErr *httpError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+
+	if errors.Is(err, github.ErrNotFound) {
+		return &httpError{http.StatusNotFound, "That org or team doesn't exist, or you can't see it.", err}
+	}
+	var rateErr *middleware.RateLimitError
+	if errors.As(err, &rateErr) {
+		return &httpError{
+			http.StatusTooManyRequests,
+			fmt.Sprintf("GitHub's API budget is nearly used up. It resets at %s.",
+				rateErr.RateLimit.Reset.Format(time.Kitchen)),
+			err,
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &httpError{http.StatusGatewayTimeout, "GitHub took too long to answer.", err}
+	}
+
+	var list gqlerror.List
+	if errors.As(err, &list) {
+		for _, gqlErr := range list {
+			switch {
+			case isRateLimit(gqlErr.Message):
+				return &httpError{http.StatusTooManyRequests, "GitHub's rate limit has been used up, try again later.", err}
+			case strings.HasPrefix(gqlErr.Message, "Could not resolve to"):
+				return &httpError{http.StatusNotFound, "That org or team doesn't exist, or you can't see it.", err}
+			}
+		}
+		return &httpError{http.StatusBadGateway, "GitHub couldn't answer the query.", err}
+	}
+
+	// genqlient reports a non-200 response as "returned error <status>: <body>"
+	var status int
+	if _, scanErr := fmt.Sscanf(err.Error(), "returned error %d", &status); scanErr == nil {
+		switch {
+		case status == http.StatusUnauthorized && app:
+			// the installation token is replaced, so it may work next time
+			return &httpError{http.StatusUnauthorized,
+				"GitHub rejected the GitHub App's token. Try again, and if it keeps happening, check the app's id and private key.", err}
+		case status == http.StatusUnauthorized:
+			return &httpError{http.StatusUnauthorized, "GitHub rejected the token, check GITHUB_TOKEN.", err}
+		case status == http.StatusTooManyRequests || (status == http.StatusForbidden && isRateLimit(err.Error())):
+			return &httpError{http.StatusTooManyRequests, "GitHub's rate limit has been used up, try again later.", err}
+		}
+	}
+
+	return &httpError{http.StatusBadGateway, "Something went wrong talking to GitHub.", err}
+}
+
+// isRateLimit reports whether a GitHub error message is about either the
+// primary or the secondary rate limit.
+func isRateLimit(message string) bool {
+	return strings.Contains(strings.ToLower(message), "rate limit")
+}
+
+// renderError logs err and shows the error page, with the status
+// from newHTTPError.
+func (s *ServerHandler) renderError(w http.ResponseWriter, err error) {
+	httpErr := newHTTPError(err, s.config.AppAuth() != nil)
+	s.logError(w, httpErr)
+
+	page := errorPage{
+		Status:     httpErr.Status,
+		StatusText: http.StatusText(httpErr.Status),
+		Message:    httpErr.Message,
+		Err:        httpErr.Err.Error(),
+	}
+	var list gqlerror.List
+	if errors.As(err, &list) {
+		page.Details = list
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	s.renderStatus(w, httpErr.Status, errorTemplate, page)
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/StevenACoffman/teamboard/pkg/logging"
+)
+
+// requestIDHeader is where a request ID is taken from, if a proxy in front
+// set one, and where it is sent back.
+const requestIDHeader = "X-Request-Id"
+
+// responseWriter remembers the status of the response, and carries the
+// logger for the request it is the response to.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	logger *logging.Logger
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// logRequests serves r with next, with a request ID on the context's
+// logger, so everything done for r can be told apart from other requests,
+// and logs how it went. Health checks and static files are only logged
+// at debug level, since there are so many of them.
+func (s *ServerHandler) logRequests(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	begin := time.Now()
+	id := requestID(r)
+	w.Header().Set(requestIDHeader, id)
+
+	logger := s.logger.With("request_id", id)
+	rw := &responseWriter{ResponseWriter: w, logger: logger}
+	next.ServeHTTP(rw, r.WithContext(logging.NewContext(r.Context(), logger)))
+
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	log := logger.Info
+	if r.URL.Path == "/health" || strings.HasPrefix(r.URL.Path, "/static/") {
+		log = logger.Debug
+	}
+	log("request",
+		"method", r.Method,
+		"path", r.URL.Path,
+		"status", rw.status,
+		"duration", time.Since(begin),
+	)
+}
+
+// requestID is the ID a proxy gave r, or else a new random one.
+func requestID(r *http.Request) string {
+	if id := r.Header.Get(requestIDHeader); id != "" && len(id) <= 64 && !strings.ContainsAny(id, " \t\r\n\"") {
+		return id
+	}
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// log is the logger for the request that w is the response to.
+func (s *ServerHandler) log(w http.ResponseWriter) *logging.Logger {
+	if rw, ok := w.(*responseWriter); ok {
+		return rw.logger
+	}
+	return s.logger
+}
+
+// logError logs the error a request is being answered with: as an error
+// if it's our fault or GitHub's, or a warning if it's the request's.
+func (s *ServerHandler) logError(w http.ResponseWriter, httpErr *httpError) {
+	log := s.log(w).Warn
+	if httpErr.Status >= http.StatusInternalServerError {
+		log = s.log(w).Error
+	}
+	log("request failed",
+		"status", httpErr.Status,
+		"message", httpErr.Message,
+		"error", httpErr.Err,
+	)
+}
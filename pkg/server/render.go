@@ -0,0 +1,112 @@
+package server
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/StevenACoffman/teamboard/pkg"
+	"github.com/StevenACoffman/teamboard/pkg/config"
+	"github.com/StevenACoffman/teamboard/pkg/github"
+	"github.com/StevenACoffman/teamboard/pkg/middleware"
+	"github.com/StevenACoffman/teamboard/pkg/types"
+)
+
+// templates live in the embedded pkg/assets folder
+const (
+	boardTemplate  = "assets/team-pr-template.html"
+	selectTemplate = "assets/select-template.html"
+	errorTemplate  = "assets/error-template.html"
+	boardsTemplate = "assets/boards-template.html"
+)
+
+// selectPage is the data for the org / team picker.
+// Teams is only filled in once an Org has been chosen.
+type selectPage struct {
+	Orgs  []string
+	Org   string
+	Teams []string
+	// Boards are the saved boards, to link to as well
+	Boards []config.Board
+	// AllOrgs links to the board for all of Orgs at once,
+	// when there is more than one.
+	AllOrgs string
+}
+
+func newSelectPage(orgs []string, org string, teams []string, boards []config.Board) selectPage {
+	page := selectPage{Orgs: orgs, Org: org, Teams: teams, Boards: boards}
+	if len(orgs) > 1 {
+		page.AllOrgs = "/?" + url.Values{"org": orgs}.Encode()
+	}
+	return page
+}
+
+// boardPage is the data for the board template: the board, and the
+// current values and choices for its sort and filter controls.
+type boardPage struct {
+	*types.Board
+	Sort   string
+	Order  string
+	Repo   string
+	Author string
+	Size   string
+	Reason string
+
+	SortKeys []github.SortBy
+	Sizes    []types.Size
+	Reasons  []types.Reason
+	// Hidden are the other query parameters, like org and team,
+	// so they are kept when the controls are submitted.
+	Hidden url.Values
+	// Clear is the link to the board without any sorting or filtering.
+	Clear string
+	// ToggleDrafts is the link to the board with drafts shown, if they
+	// are hidden, or hidden, if they are shown.
+	ToggleDrafts string
+	// RateLimit is GitHub's API budget, if it is known.
+	RateLimit *middleware.RateLimit
+	// Cost is how many points of the budget the board took, which is
+	// none if it was all cached.
+	Cost int
+}
+
+// templateFuncs are the functions the templates can call, on top of
+// html/template's own.
+var templateFuncs = template.FuncMap{
+	// pathEscape makes a value safe as one segment of a URL path,
+	// like the name in /board/<name>
+	"pathEscape": url.PathEscape,
+}
+
+// render executes the named embedded template with data and writes it out.
+// The template is executed into a buffer first, so a failure part way
+// through doesn't leave the browser with half a page.
+func (s *ServerHandler) render(w http.ResponseWriter, name string, data interface{}) {
+	s.renderStatus(w, http.StatusOK, name, data)
+}
+
+// renderStatus is render, with a status other than 200 OK.
+func (s *ServerHandler) renderStatus(w http.ResponseWriter, status int, name string, data interface{}) {
+	t, err := template.New(path.Base(name)).Funcs(templateFuncs).ParseFS(pkg.AssetData, name)
+	if err != nil {
+		s.log(w).Error("unable to parse template", "template", name, "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	if err := t.Execute(buf, data); err != nil {
+		s.log(w).Error("unable to execute template", "template", name, "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(status)
+	_, err = w.Write(buf.Bytes())
+	// TODO: this is not good error handling
+	if err != nil {
+		s.log(w).Warn("error writing response", "error", err)
+	}
+}
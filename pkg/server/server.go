@@ -1,33 +1,43 @@
 package server
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"github.com/Khan/genqlient/graphql"
 	"github.com/StevenACoffman/teamboard/pkg"
+	"github.com/StevenACoffman/teamboard/pkg/cache"
+	"github.com/StevenACoffman/teamboard/pkg/config"
 	"github.com/StevenACoffman/teamboard/pkg/github"
-	"log"
+	"github.com/StevenACoffman/teamboard/pkg/logging"
+	"github.com/StevenACoffman/teamboard/pkg/middleware"
+	"github.com/StevenACoffman/teamboard/pkg/types"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
-	"text/template"
 	"time"
 )
 
-func RunServer(logger *log.Logger, graphqlClient graphql.Client) error {
+func RunServer(
+	logger *logging.Logger,
+	graphqlClient graphql.Client,
+	cfg config.Config,
+	rateLimiter RateLimiter,
+) error {
 	// =========================================================================
 	// Start API Service
-	api := NewHTTPServer(logger, graphqlClient)
+	api := NewHTTPServer(logger, graphqlClient, cfg, rateLimiter)
 	// Make a channel to listen for errors coming from the listener. Use a
 	// buffered channel so the goroutine can exit if we don't collect this error.
 	serverErrors := make(chan error, 1)
 
 	// Start the service listening for requests.
 	go func() {
-		logger.Printf("main : API listening on %s", api.Addr)
+		logger.Info("listening", "addr", api.Addr)
 		// listen and serve blocks until error or shutdown is called
 		serverErrors <- api.ListenAndServe()
 	}()
@@ -48,11 +58,11 @@ func RunServer(logger *log.Logger, graphqlClient graphql.Client) error {
 	// Blocking main and waiting for shutdown.
 	select {
 	case err := <-serverErrors:
-		logger.Fatalf("error: listening and serving: %s", err)
+		logger.Error("listening and serving", "error", err)
 		return err
 
 	case <-shutdown:
-		logger.Println("runServer : Start shutdown")
+		logger.Info("shutting down")
 
 		// Give outstanding requests a deadline for completion.
 		const timeout = 5 * time.Second
@@ -62,7 +72,10 @@ func RunServer(logger *log.Logger, graphqlClient graphql.Client) error {
 		// Asking listener to shutdown and load shed.
 		err := api.Shutdown(ctx)
 		if err != nil {
-			logger.Printf("runServer : Graceful shutdown did not complete in %v : %v", timeout, err)
+			logger.Warn("graceful shutdown did not complete",
+				"timeout", timeout,
+				"error", err,
+			)
 			err = api.Close()
 			return err
 		}
@@ -70,14 +83,28 @@ func RunServer(logger *log.Logger, graphqlClient graphql.Client) error {
 	}
 }
 
+// WriteTimeout is how long a response can take. RequestTimeout is how long
+// everything asked of GitHub for it can take together, retries and waits
+// for the rate limit included, which leaves time to still answer with an
+// error page if GitHub is slow.
+const (
+	WriteTimeout   = 10 * time.Second
+	RequestTimeout = 8 * time.Second
+)
+
 // NewHTTPServer is factory function to initialize a new server
-func NewHTTPServer(logger *log.Logger, graphqlClient graphql.Client) *http.Server {
-	addr := ":" + os.Getenv("PORT")
-	if addr == ":" {
-		addr = ":3000"
+func NewHTTPServer(
+	logger *logging.Logger,
+	graphqlClient graphql.Client,
+	cfg config.Config,
+	rateLimiter RateLimiter,
+) *http.Server {
+	addr := cfg.Addr
+	if addr == "" {
+		addr = config.DefaultAddr
 	}
 
-	s := &ServerHandler{graphqlClient: graphqlClient}
+	s := &ServerHandler{graphqlClient: graphqlClient, config: cfg, rateLimiter: rateLimiter}
 	// pass logger
 	s.SetLogger(logger)
 
@@ -85,7 +112,7 @@ func NewHTTPServer(logger *log.Logger, graphqlClient graphql.Client) *http.Serve
 		Addr:         addr,
 		Handler:      s,
 		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		WriteTimeout: WriteTimeout,
 	}
 
 	return h
@@ -93,14 +120,53 @@ func NewHTTPServer(logger *log.Logger, graphqlClient graphql.Client) *http.Serve
 
 // ServerHandler implements type http.Handler interface, with our logger
 type ServerHandler struct {
-	logger *log.Logger
-	mux    *http.ServeMux
-	once   sync.Once
+	logger        *logging.Logger
+	mux           *http.ServeMux
+	once          sync.Once
 	graphqlClient graphql.Client
+	config        config.Config
+	rateLimiter   RateLimiter
+}
+
+// RateLimiter reports how much of GitHub's API budget is left,
+// like middleware.RateLimitRoundTripper
+type RateLimiter interface {
+	RateLimit() middleware.RateLimit
+}
+
+// rateLimit is the API budget, if it is known yet.
+func (s *ServerHandler) rateLimit() *middleware.RateLimit {
+	if s.rateLimiter == nil {
+		return nil
+	}
+	limit := s.rateLimiter.RateLimit()
+	if !limit.Known() {
+		return nil
+	}
+	return &limit
+}
+
+// myLogin is who the board is for: the configured login, or whoever the
+// token belongs to. It's empty for a GitHub App without a configured
+// login, which only shows what the team is involved in.
+func (s *ServerHandler) myLogin(ctx context.Context) (string, error) {
+	if login, ok := s.config.FixedLogin(); ok {
+		return login, nil
+	}
+	return github.GetLogin(ctx, s.graphqlClient)
+}
+
+// myOrgs are the orgs to pick from: the ones you belong to, or for a
+// GitHub App, the configured ones.
+func (s *ServerHandler) myOrgs(ctx context.Context) ([]string, error) {
+	if s.config.AppAuth() != nil {
+		return s.config.AppOrgs(), nil
+	}
+	return github.GetOrgs(ctx, s.graphqlClient)
 }
 
 // SetLogger provides external injection of logger
-func (s *ServerHandler) SetLogger(logger *log.Logger) {
+func (s *ServerHandler) SetLogger(logger *logging.Logger) {
 	s.logger = logger
 }
 
@@ -109,10 +175,8 @@ func (s *ServerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// on the first request only, lazily initialize
 	s.once.Do(func() {
 		if s.logger == nil {
-			s.logger = log.New(os.Stdout,
-				"INFO: ",
-				log.Ldate|log.Ltime|log.Lshortfile)
-			s.logger.Printf("Default Logger used")
+			s.logger = logging.Default
+			s.logger.Debug("default logger used")
 		}
 		s.mux = http.NewServeMux()
 
@@ -122,81 +186,196 @@ func (s *ServerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			http.StripPrefix("/static/",
 				http.FileServer(http.FS(pkg.AssetData))))
 		s.mux.HandleFunc("/redirect", s.RedirectToHome)
+		s.mux.HandleFunc("/select", s.SelectBoard)
+		s.mux.HandleFunc("/board/", s.BoardPage)
 		s.mux.HandleFunc("/health", HealthCheck)
+		s.mux.HandleFunc("/metrics", s.Metrics)
+		s.mux.HandleFunc("/api/orgs", s.APIOrgs)
+		s.mux.HandleFunc("/api/teams", s.APITeams)
+		s.mux.HandleFunc("/api/pulls", s.APIPulls)
 		s.mux.HandleFunc("/", s.DefaultPage)
 	})
 
-	s.mux.ServeHTTP(w, r)
+	// ?refresh=1 on any page skips the cache and asks GitHub again
+	if refresh, _ := strconv.ParseBool(r.URL.Query().Get("refresh")); refresh {
+		r = r.WithContext(cache.WithRefresh(r.Context()))
+	}
+	// the board shows how much of GitHub's budget it took
+	ctx, _ := middleware.WithCost(r.Context())
+	ctx, cancel := context.WithTimeout(ctx, RequestTimeout)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	s.logRequests(w, r, s.mux)
 }
 
 func (s *ServerHandler) DefaultPage(w http.ResponseWriter, req *http.Request) {
 
 	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
 
-	//TODO: If unset, the a page with a form to set these should be displayed
-	org := req.URL.Query().Get("org")
-	if org == "" {
-		org = "Khan"
+	orgs, team := s.orgsAndTeam(req)
+
+	myLogin, err := s.myLogin(req.Context())
+	if err != nil {
+		s.renderError(w, err)
+		return
 	}
 
-	team := req.URL.Query().Get("team")
+	// Without an org there is nothing to look teams up in, so ask for one.
+	if len(orgs) == 0 {
+		myOrgs, err := s.myOrgs(req.Context())
+		if err != nil {
+			s.renderError(w, err)
+			return
+		}
+		s.render(w, selectTemplate, newSelectPage(myOrgs, "", nil, s.config.Boards))
+		return
+	}
+
+	// Several orgs are shown together, with or without a team.
+	if len(orgs) > 1 {
+		board, err := s.getBoard(req, myLogin, orgs, team, s.config.SearchOptions())
+		if err != nil {
+			s.renderError(w, err)
+			return
+		}
+		board.Title = strings.Join(orgs, ", ")
+		if team != "" {
+			board.Title += " / " + team
+		}
+		s.renderBoard(w, req, board)
+		return
+	}
+	org := orgs[0]
+
+	// Without a team, ask for one. The orgs and teams to pick from are
+	// only looked up here, so showing a board doesn't spend the budget.
 	if team == "" {
-		team = "districts"
+		myOrgs, err := s.myOrgs(req.Context())
+		if err != nil {
+			s.renderError(w, err)
+			return
+		}
+		myTeams, err := github.GetTeams(req.Context(), s.graphqlClient, myLogin, org)
+		if err != nil {
+			s.renderError(w, err)
+			return
+		}
+		s.render(w, selectTemplate, newSelectPage(myOrgs, org, myTeams, s.config.Boards))
+		return
 	}
 
-	myLogin, err := github.GetLogin(req.Context(), s.graphqlClient)
-	// TODO: this is not good error handling
+	board, err := s.getBoard(req, myLogin, orgs, team, s.config.SearchOptions())
 	if err != nil {
-		fmt.Printf("%+v\n", err)
+		s.renderError(w, err)
 		return
 	}
-	var myOrgs []string
-	myOrgs, err = github.GetOrgs(req.Context(), s.graphqlClient)
-	// TODO: this is not good error handling
-	if err != nil {
-		fmt.Printf("%+v\n", err)
+	board.Title = org + "/" + team
+
+	s.renderBoard(w, req, board)
+}
+
+// orgsAndTeam are the orgs and team in the query parameters of req, falling
+// back to the configured defaults. There can be more than one ?org=
+// The default team only applies along with the default org.
+func (s *ServerHandler) orgsAndTeam(req *http.Request) ([]string, string) {
+	var orgs []string
+	seen := make(map[string]bool)
+	for _, org := range req.URL.Query()["org"] {
+		if org != "" && !seen[strings.ToLower(org)] {
+			seen[strings.ToLower(org)] = true
+			orgs = append(orgs, org)
+		}
+	}
+	team := req.URL.Query().Get("team")
+	if len(orgs) == 0 {
+		orgs = s.config.DefaultOrgs()
+		if team == "" {
+			team = s.config.Team
+		}
+	}
+	if team == "" && len(orgs) == 1 && orgs[0] == s.config.Org {
+		team = s.config.Team
+	}
+	return orgs, team
+}
+
+// getBoard gets the pull requests for team in orgs, honouring the
+// options in the query parameters of req.
+func (s *ServerHandler) getBoard(
+	req *http.Request,
+	myLogin string,
+	orgs []string,
+	team string,
+	searchOptions github.SearchOptions,
+) (*types.Board, error) {
+	if children := req.URL.Query().Get("children"); children != "" {
+		searchOptions.ChildTeams, _ = strconv.ParseBool(children)
+	}
+	if drafts := req.URL.Query().Get("drafts"); drafts != "" {
+		searchOptions.Drafts, _ = strconv.ParseBool(drafts)
+	}
+	return github.GetBoard(req.Context(), s.graphqlClient, myLogin, orgs, team, searchOptions)
+}
+
+// BoardPage serves the saved board named in the path, /board/<name>,
+// or an index of all the saved boards at /board/
+func (s *ServerHandler) BoardPage(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+
+	name := strings.Trim(strings.TrimPrefix(req.URL.Path, "/board/"), "/")
+	if name == "" {
+		s.render(w, boardsTemplate, s.config.Boards)
 		return
 	}
-	var myTeams []string
 
-	myTeams, err = github.GetTeams(req.Context(), s.graphqlClient, myLogin, org)
-	// TODO: this is not good error handling
-	if err != nil {
-		fmt.Printf("%+v\n", err)
+	saved, ok := s.config.Board(name)
+	if !ok {
+		s.renderError(w, &httpError{
+			Status:  http.StatusNotFound,
+			Message: fmt.Sprintf("There is no saved board called %q.", name),
+			Err:     fmt.Errorf("board %q: %w", name, github.ErrNotFound),
+		})
 		return
 	}
 
-	var teammates []string
-	teammates, err = github.GetTeamMembers(req.Context(), s.graphqlClient, org, team)
-	// TODO: this is not good error handling
+	myLogin, err := s.myLogin(req.Context())
 	if err != nil {
-		fmt.Printf("%+v\n", err)
+		s.renderError(w, err)
 		return
 	}
-	fmt.Println("<!-- myTeams: ", myTeams, " -->")
-	fmt.Println("<!-- myOrgs: ", myOrgs, " -->")
-	fmt.Println("<!-- TeamMates: ", teammates, " -->")
-	pulls, pullsErr := github.GetPulls(req.Context(), s.graphqlClient, myLogin, org, team, teammates)
-	if pullsErr != nil {
-		fmt.Printf("%+v\n", pullsErr)
+	board, err := s.getBoard(req, myLogin, []string{saved.Org}, saved.Team, s.config.BoardSearchOptions(saved))
+	if err != nil {
+		s.renderError(w, err)
 		return
 	}
+	board.Title = saved.Name
 
-	t, tmplParseErr := template.ParseFS(pkg.AssetData, "assets/team-pr-template.html")
-	if tmplParseErr != nil {
-		fmt.Println(tmplParseErr)
-	}
+	s.renderBoard(w, req, board)
+}
 
-	buf := &bytes.Buffer{}
-	if err := t.Execute(buf, pulls); err != nil {
-		panic(err)
+// SelectBoard receives the org / team picker form and redirects to the
+// dashboard for that pair. If only the org was chosen, it redirects back to
+// the picker so the team dropdown can be filled in for that org. Several
+// orgs go straight to the board for all of them.
+func (s *ServerHandler) SelectBoard(w http.ResponseWriter, r *http.Request) {
+	q := url.Values{}
+	for _, org := range r.URL.Query()["org"] {
+		if org != "" {
+			q.Add("org", org)
+		}
 	}
-	fragment := buf.String()
-	_, err = w.Write([]byte(fragment))
-	// TODO: this is not good error handling
-	if err != nil {
-		s.logger.Println("error writing:", err)
+	if len(q) > 0 {
+		if team := r.URL.Query().Get("team"); team != "" {
+			q.Set("team", team)
+		}
 	}
+	location := "/"
+	if len(q) > 0 {
+		location += "?" + q.Encode()
+	}
+	w.Header().Add("location", location)
+	w.WriteHeader(http.StatusSeeOther)
 }
 
 // HealthCheck verifies externally that the program is still responding
@@ -206,9 +385,29 @@ func HealthCheck(w http.ResponseWriter, _ *http.Request) {
 	w.WriteHeader(200)
 }
 
+// Metrics reports GitHub's API budget, and the response cache hit counts
+// per GraphQL operation, in the Prometheus text format.
+func (s *ServerHandler) Metrics(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if limit := s.rateLimit(); limit != nil {
+		fmt.Fprintf(w, "teamboard_github_rate_limit_remaining %d\n", limit.Remaining)
+		fmt.Fprintf(w, "teamboard_github_rate_limit_limit %d\n", limit.Limit)
+		fmt.Fprintf(w, "teamboard_github_rate_limit_reset_seconds %d\n", limit.Reset.Unix())
+	}
+	c, ok := s.graphqlClient.(*cache.Client)
+	if !ok {
+		return
+	}
+	for _, stats := range c.Stats() {
+		fmt.Fprintf(w, "teamboard_cache_hits_total{operation=%q} %d\n", stats.Operation, stats.Hits)
+		fmt.Fprintf(w, "teamboard_cache_misses_total{operation=%q} %d\n", stats.Operation, stats.Misses)
+		fmt.Fprintf(w, "teamboard_cache_refreshes_total{operation=%q} %d\n", stats.Operation, stats.Refreshes)
+	}
+}
+
 // RedirectToHome Will Log the Request, and respond with a HTTP 303 to redirect to /
 func (s *ServerHandler) RedirectToHome(w http.ResponseWriter, r *http.Request) {
-	s.logger.Printf("Redirected request %v to /", r.RequestURI)
+	s.log(w).Info("redirected to /", "uri", r.RequestURI)
 	w.Header().Add("location", "/")
 	w.WriteHeader(http.StatusSeeOther)
 }
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/StevenACoffman/teamboard/pkg/config"
+	"github.com/StevenACoffman/teamboard/pkg/logging"
+)
+
+// newTestHandler is a handler that doesn't need GitHub, for the pages
+// that are served before anything is asked of it.
+func newTestHandler(cfg config.Config) http.Handler {
+	logger := logging.New(ioutil.Discard, logging.FormatLogfmt, logging.LevelError)
+	return NewHTTPServer(logger, nil, cfg, nil).Handler
+}
+
+func TestBoardPageEscapesName(t *testing.T) {
+	h := newTestHandler(config.Config{})
+
+	req := httptest.NewRequest(http.MethodGet, "/board/%3Cscript%3Ealert(1)%3C%2Fscript%3E", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	body := w.Body.String()
+	if strings.Contains(body, "<script>alert(1)</script>") {
+		t.Errorf("board name was not escaped:\n%s", body)
+	}
+	if !strings.Contains(body, "&lt;script&gt;alert(1)&lt;/script&gt;") {
+		t.Errorf("escaped board name missing from:\n%s", body)
+	}
+}
+
+func TestBoardLinksEscapeName(t *testing.T) {
+	h := newTestHandler(config.Config{
+		Boards: []config.Board{{Name: `infra?team=x#"<b>`, Org: "Khan", Team: "infra"}},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/board/", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	body := w.Body.String()
+	want := `href="/board/infra%3Fteam=x%23%22%3Cb%3E"`
+	if !strings.Contains(body, want) {
+		t.Errorf("want link %s in:\n%s", want, body)
+	}
+	if strings.Contains(body, "<b>") {
+		t.Errorf("board name was not escaped:\n%s", body)
+	}
+}
+
+func TestBadViewParamsAreEscaped(t *testing.T) {
+	s := &ServerHandler{logger: logging.New(ioutil.Discard, logging.FormatLogfmt, logging.LevelError)}
+	for _, query := range []string{
+		"sort=%3Cscript%3Ealert(1)%3C%2Fscript%3E",
+		"sort=size&order=%3Cscript%3Ealert(1)%3C%2Fscript%3E",
+		"size=%3Cscript%3Ealert(1)%3C%2Fscript%3E",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/?org=Khan&team=districts&"+query, nil)
+		_, err := parseView(req)
+		if err == nil {
+			t.Errorf("%s: want an error", query)
+			continue
+		}
+
+		w := httptest.NewRecorder()
+		s.renderError(w, err)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, w.Code, http.StatusBadRequest)
+		}
+		if body := w.Body.String(); strings.Contains(body, "<script>") {
+			t.Errorf("%s: parameter was not escaped:\n%s", query, body)
+		}
+	}
+}
+
+// deadlineClient is a GraphQL client that fails every request, and
+// remembers the deadline of the last one.
+type deadlineClient struct {
+	deadline time.Time
+	ok       bool
+}
+
+func (c *deadlineClient) MakeRequest(ctx context.Context, _, _ string, _ interface{}, _ map[string]interface{}) error {
+	c.deadline, c.ok = ctx.Deadline()
+	return errors.New("no GitHub in tests")
+}
+
+func TestRequestsToGitHubHaveADeadline(t *testing.T) {
+	client := &deadlineClient{}
+	logger := logging.New(ioutil.Discard, logging.FormatLogfmt, logging.LevelError)
+	h := NewHTTPServer(logger, client, config.Config{}, nil).Handler
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/orgs", nil))
+	done := time.Now()
+
+	if !client.ok {
+		t.Fatal("the request to GitHub had no deadline")
+	}
+	if left := client.deadline.Sub(done); left > RequestTimeout || RequestTimeout >= WriteTimeout {
+		t.Errorf("the request to GitHub had %s, want at most %s, less than the %s to write the page",
+			left, RequestTimeout, WriteTimeout)
+	}
+}
+
+func TestUnauthorizedMessage(t *testing.T) {
+	err := errors.New(`returned error 401 Unauthorized: {"message":"Bad credentials"}`)
+
+	token := newHTTPError(err, false)
+	if token.Status != http.StatusUnauthorized || !strings.Contains(token.Message, "GITHUB_TOKEN") {
+		t.Errorf("with a token, got %d %q, want a 401 about GITHUB_TOKEN", token.Status, token.Message)
+	}
+	app := newHTTPError(err, true)
+	if app.Status != http.StatusUnauthorized || strings.Contains(app.Message, "GITHUB_TOKEN") ||
+		!strings.Contains(app.Message, "GitHub App") {
+		t.Errorf("with a GitHub App, got %d %q, want a 401 about the app", app.Status, app.Message)
+	}
+}
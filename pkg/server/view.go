@@ -0,0 +1,143 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/StevenACoffman/teamboard/pkg/github"
+	"github.com/StevenACoffman/teamboard/pkg/middleware"
+	"github.com/StevenACoffman/teamboard/pkg/types"
+)
+
+// viewParams are the query parameters that sort and filter the board,
+// rather than pick which board it is.
+var viewParams = []string{"sort", "order", "repo", "author", "size", "reason"}
+
+// view is how the board is sorted and filtered, from the query parameters:
+// ?sort= age, size, repo, author or updated, ?order= asc or desc, and any
+// of ?repo=, ?author=, ?size= and ?reason=, which can be repeated or
+// comma separated.
+type view struct {
+	Sort   github.Sort
+	Filter github.Filter
+}
+
+// parseView reads the view from the query parameters of req.
+func parseView(req *http.Request) (view, error) {
+	q := req.URL.Query()
+	var v view
+	var err error
+
+	v.Sort, err = github.ParseSort(q.Get("sort"), q.Get("order"))
+	if err != nil {
+		return v, badParam(err)
+	}
+	v.Filter.Repos = listParam(q, "repo")
+	v.Filter.Authors = listParam(q, "author")
+	v.Filter.Sizes, err = github.ParseSizes(listParam(q, "size"))
+	if err != nil {
+		return v, badParam(err)
+	}
+	for _, reason := range listParam(q, "reason") {
+		v.Filter.Reasons = append(v.Filter.Reasons, types.Reason(reason))
+	}
+	return v, nil
+}
+
+// apply sorts and filters board.
+func (v view) apply(board *types.Board) {
+	v.Filter.Board(board)
+	v.Sort.Board(board)
+}
+
+// renderBoard renders board, sorted and filtered the way req asks.
+func (s *ServerHandler) renderBoard(w http.ResponseWriter, req *http.Request, board *types.Board) {
+	v, err := parseView(req)
+	if err != nil {
+		s.renderError(w, err)
+		return
+	}
+	page := newBoardPage(board, req)
+	page.RateLimit = s.rateLimit()
+	page.Cost = middleware.CostFrom(req.Context()).Points()
+	v.apply(board)
+	s.render(w, boardTemplate, page)
+}
+
+// listParam is every value of the query parameter name, split on commas.
+func listParam(q url.Values, name string) []string {
+	var list []string
+	for _, value := range q[name] {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
+func badParam(err error) error {
+	return &httpError{
+		Status:  http.StatusBadRequest,
+		Message: "The board can't be sorted or filtered that way.",
+		Err:     err,
+	}
+}
+
+// newBoardPage is the data for the board template, with the sort and filter
+// controls filled in from req. It must be called before the view is
+// applied, so every reason on the board is offered.
+func newBoardPage(board *types.Board, req *http.Request) boardPage {
+	q := req.URL.Query()
+	page := boardPage{
+		Board:    board,
+		Sort:     q.Get("sort"),
+		Order:    q.Get("order"),
+		Repo:     q.Get("repo"),
+		Author:   q.Get("author"),
+		Size:     q.Get("size"),
+		Reason:   q.Get("reason"),
+		SortKeys: github.SortKeys,
+		Sizes:    types.Sizes,
+		Hidden:   url.Values{},
+	}
+
+	seen := make(map[types.Reason]bool)
+	for _, section := range board.Sections {
+		for _, reason := range section.Reasons {
+			if !seen[reason] {
+				seen[reason] = true
+				page.Reasons = append(page.Reasons, reason)
+			}
+		}
+	}
+
+	for name, values := range q {
+		if name != "refresh" && !isViewParam(name) {
+			page.Hidden[name] = values
+		}
+	}
+	page.Clear = "?" + page.Hidden.Encode()
+
+	toggled := url.Values{}
+	for name, values := range q {
+		if name != "refresh" {
+			toggled[name] = values
+		}
+	}
+	toggled.Set("drafts", strconv.FormatBool(!board.Drafts))
+	page.ToggleDrafts = "?" + toggled.Encode()
+	return page
+}
+
+func isViewParam(name string) bool {
+	for _, param := range viewParams {
+		if param == name {
+			return true
+		}
+	}
+	return false
+}
@@ -3,6 +3,7 @@ package types
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -44,6 +45,8 @@ type PullRequest struct {
 	Repository Repository `json:"repository"`
 	// Identifies the date and time when the object was created.
 	CreatedAt time.Time `json:"createdAt"`
+	// Identifies the date and time when the object was last updated.
+	UpdatedAt time.Time `json:"updatedAt"`
 	// The date and time that the pull request was merged.
 	MergedAt time.Time `json:"mergedAt"`
 	// The HTTP URL for this pull request.
@@ -55,7 +58,389 @@ type PullRequest struct {
 	// The number of deletions in this pull request.
 	Deletions int `json:"deletions"`
 	// Identifies if the pull request is a draft.
-	IsDraft bool `json:isDraft`
+	IsDraft bool `json:"isDraft"`
+	// The current status of this pull request with respect to code review.
+	ReviewDecision ReviewDecision `json:"reviewDecision"`
+	// Whether or not the pull request can be merged.
+	Mergeable MergeableState `json:"mergeable"`
+	// Only the latest commit, for its status.
+	Commits Commits `json:"commits"`
+	// The reviewers whose reviews are still requested.
+	ReviewRequests ReviewRequests `json:"reviewRequests"`
+	// Only the latest review request.
+	TimelineItems TimelineItems `json:"timelineItems"`
+	// Age is how long the pull request has been open, in working time,
+	// like 3d. Not part of the GraphQL type.
+	Age string `json:"age,omitempty"`
+	// WaitingSince is when the pull request started waiting on someone.
+	// Not part of the GraphQL type, see SetWaitingSince.
+	WaitingSince time.Time `json:"waitingSince"`
+	// Due is when the pull request is overdue, if any of its reasons
+	// have an SLA. Not part of the GraphQL type.
+	Due *time.Time `json:"due,omitempty"`
+	// Why this pull request is on the board. Not part of the GraphQL type,
+	// this is filled in from which searches found it.
+	Reasons []Reason `json:"reasons,omitempty"`
+}
+
+// HasReason reports whether r is one of the reasons for the pull request.
+func (v *PullRequest) HasReason(r Reason) bool {
+	for _, reason := range v.Reasons {
+		if reason == r {
+			return true
+		}
+	}
+	return false
+}
+
+// AddReason adds r to the reasons for the pull request, unless it is
+// already there.
+func (v *PullRequest) AddReason(r Reason) {
+	if !v.HasReason(r) {
+		v.Reasons = append(v.Reasons, r)
+	}
+}
+
+// CheckState is the combined state of the checks and statuses on the pull
+// request's latest commit, or "" if it has none.
+func (v *PullRequest) CheckState() StatusState {
+	if len(v.Commits.Nodes) == 0 {
+		return ""
+	}
+	rollup := v.Commits.Nodes[len(v.Commits.Nodes)-1].Commit.StatusCheckRollup
+	if rollup == nil {
+		return ""
+	}
+	return rollup.State
+}
+
+// RequestedReviewers are the logins of the people, and slugs of the teams,
+// whose reviews are still requested.
+func (v *PullRequest) RequestedReviewers() []string {
+	var reviewers []string
+	for _, request := range v.ReviewRequests.Nodes {
+		if name := request.RequestedReviewer.Name(); name != "" {
+			reviewers = append(reviewers, name)
+		}
+	}
+	return reviewers
+}
+
+// SetWaitingSince works out WaitingSince: when review was last requested,
+// if it still is, or else when it was last pushed to, or else when it was
+// created.
+func (v *PullRequest) SetWaitingSince() {
+	v.WaitingSince = v.CreatedAt
+	if len(v.ReviewRequests.Nodes) > 0 {
+		for _, item := range v.TimelineItems.Nodes {
+			if item.CreatedAt.After(v.WaitingSince) {
+				v.WaitingSince = item.CreatedAt
+			}
+		}
+		if v.WaitingSince.After(v.CreatedAt) {
+			return
+		}
+	}
+	if len(v.Commits.Nodes) > 0 {
+		commit := v.Commits.Nodes[len(v.Commits.Nodes)-1].Commit
+		pushed := commit.PushedDate
+		if pushed.IsZero() {
+			pushed = commit.CommittedDate
+		}
+		if pushed.After(v.WaitingSince) {
+			v.WaitingSince = pushed
+		}
+	}
+}
+
+// Overdue reports whether the pull request has been waiting longer than
+// its SLA.
+func (v *PullRequest) Overdue() bool {
+	return v.Due != nil && time.Now().After(*v.Due)
+}
+
+// Size is how big the pull request is, by the number of lines it adds
+// and deletes.
+func (v *PullRequest) Size() Size {
+	lines := v.Additions + v.Deletions
+	for _, size := range Sizes[:len(Sizes)-1] {
+		if lines < sizeLimits[size] {
+			return size
+		}
+	}
+	return SizeXL
+}
+
+// Org is the owner of the pull request's repository, like Khan
+func (v *PullRequest) Org() string {
+	return strings.SplitN(v.Repository.NameWithOwner, "/", 2)[0]
+}
+
+// Reason is why a pull request appears on the board,
+// which is to say, which search found it.
+type Reason string
+
+const (
+	// ReasonReviewRequested means your review was requested.
+	ReasonReviewRequested Reason = "review-requested"
+	// ReasonMentioned means you were mentioned.
+	ReasonMentioned Reason = "mentioned"
+	// ReasonTeamAuthored means a teammate wrote it.
+	ReasonTeamAuthored Reason = "team-authored"
+	// ReasonTeamMentioned means your team was mentioned.
+	ReasonTeamMentioned Reason = "team-mentioned"
+	// ReasonTeamRequested means your team's review was requested.
+	ReasonTeamRequested Reason = "team-review-requested"
+	// ReasonRepo means it is in one of the extra repositories being watched.
+	ReasonRepo Reason = "repo"
+)
+
+// Reasons are all the reasons a pull request can be on the board.
+var Reasons = []Reason{
+	ReasonReviewRequested,
+	ReasonMentioned,
+	ReasonTeamAuthored,
+	ReasonTeamMentioned,
+	ReasonTeamRequested,
+	ReasonRepo,
+}
+
+// Label is a short human readable description of the reason.
+func (r Reason) Label() string {
+	switch r {
+	case ReasonReviewRequested:
+		return "Review requested"
+	case ReasonMentioned:
+		return "Mentioned"
+	case ReasonTeamAuthored:
+		return "Teammate authored"
+	case ReasonTeamMentioned:
+		return "Team mentioned"
+	case ReasonTeamRequested:
+		return "Team review requested"
+	case ReasonRepo:
+		return "Watched repo"
+	default:
+		return string(r)
+	}
+}
+
+// ReviewDecision is the GraphQL enum PullRequestReviewDecision.
+// It is "" when the repository doesn't require reviews.
+type ReviewDecision string
+
+const (
+	// ReviewApproved means the pull request has an approving review.
+	ReviewApproved ReviewDecision = "APPROVED"
+	// ReviewChangesRequested means changes have been requested.
+	ReviewChangesRequested ReviewDecision = "CHANGES_REQUESTED"
+	// ReviewRequired means it can't be merged until it is reviewed.
+	ReviewRequired ReviewDecision = "REVIEW_REQUIRED"
+)
+
+// MergeableState is the GraphQL enum MergeableState.
+type MergeableState string
+
+const (
+	// MergeableConflicting means there are merge conflicts.
+	MergeableConflicting MergeableState = "CONFLICTING"
+	// MergeableMergeable means it can be merged.
+	MergeableMergeable MergeableState = "MERGEABLE"
+	// MergeableUnknown means GitHub is still working it out.
+	MergeableUnknown MergeableState = "UNKNOWN"
+)
+
+// StatusState is the GraphQL enum StatusState, the combined state of
+// a commit's checks and statuses.
+type StatusState string
+
+const (
+	// StatusError means a check errored.
+	StatusError StatusState = "ERROR"
+	// StatusExpected means a check is expected, but hasn't started.
+	StatusExpected StatusState = "EXPECTED"
+	// StatusFailure means a check failed.
+	StatusFailure StatusState = "FAILURE"
+	// StatusPending means a check is still running.
+	StatusPending StatusState = "PENDING"
+	// StatusSuccess means every check passed.
+	StatusSuccess StatusState = "SUCCESS"
+)
+
+// Failing reports whether the checks have failed or errored.
+func (s StatusState) Failing() bool {
+	return s == StatusFailure || s == StatusError
+}
+
+// Size is a rough size bucket for a pull request, like m
+type Size string
+
+const (
+	// SizeXS is under 10 lines changed.
+	SizeXS Size = "xs"
+	// SizeS is under 100 lines changed.
+	SizeS Size = "s"
+	// SizeM is under 500 lines changed.
+	SizeM Size = "m"
+	// SizeL is under 1000 lines changed.
+	SizeL Size = "l"
+	// SizeXL is 1000 lines changed or more.
+	SizeXL Size = "xl"
+)
+
+// Sizes are all the sizes, smallest first.
+var Sizes = []Size{SizeXS, SizeS, SizeM, SizeL, SizeXL}
+
+// sizeLimits are the number of lines changed each size is under.
+var sizeLimits = map[Size]int{
+	SizeXS: 10,
+	SizeS:  100,
+	SizeM:  500,
+	SizeL:  1000,
+}
+
+// Board is the pull requests for a dashboard, split into sections.
+type Board struct {
+	// Title is what the board is called, like Khan/districts
+	Title string `json:"title,omitempty"`
+	// Orgs are the orgs searched, when there is more than one.
+	Orgs []string `json:"orgs,omitempty"`
+	// Drafts is whether draft pull requests were searched for.
+	Drafts   bool      `json:"drafts,omitempty"`
+	Sections []Section `json:"sections"`
+}
+
+// MultiOrg reports whether the board is for more than one org.
+func (b *Board) MultiOrg() bool {
+	return len(b.Orgs) > 1
+}
+
+// Pulls returns the pull requests in every section of the board, once
+// each, even if they are in more than one.
+func (b *Board) Pulls() []PullRequest {
+	var pulls []PullRequest
+	seen := make(map[string]bool)
+	for _, section := range b.Sections {
+		for _, pull := range section.Pulls {
+			if !seen[pull.Url] {
+				seen[pull.Url] = true
+				pulls = append(pulls, pull)
+			}
+		}
+	}
+	return pulls
+}
+
+// Section is a group of pull requests on the board that share a reason
+// for being there, like "Needs your review".
+type Section struct {
+	// ID identifies the section, and is safe to use in URLs and HTML.
+	ID string `json:"id"`
+	// Title is the heading shown for the section.
+	Title string `json:"title"`
+	// Empty is shown instead of the pull requests when there are none.
+	Empty string `json:"-"`
+	// Reasons are the reasons a pull request needs to be in the section.
+	Reasons []Reason `json:"reasons"`
+	// Overlaps lets the section have pull requests that are already in
+	// an earlier one, like those of a custom query.
+	Overlaps bool `json:"-"`
+	// Pulls are the pull requests in the section.
+	Pulls []PullRequest `json:"pulls"`
+}
+
+// Count is the number of pull requests in the section.
+func (s *Section) Count() int {
+	return len(s.Pulls)
+}
+
+// Matches reports whether the pull request belongs in the section.
+func (s *Section) Matches(pull PullRequest) bool {
+	for _, reason := range s.Reasons {
+		if pull.HasReason(reason) {
+			return true
+		}
+	}
+	return false
+}
+
+// Commits includes the requested fields of the GraphQL type
+// PullRequestCommitConnection.
+type Commits struct {
+	// A list of nodes.
+	Nodes []CommitNode `json:"nodes"`
+}
+
+// CommitNode includes the requested fields of the GraphQL type
+// PullRequestCommit.
+type CommitNode struct {
+	// The Git commit object
+	Commit Commit `json:"commit"`
+}
+
+// Commit includes the requested fields of the GraphQL type Commit.
+type Commit struct {
+	// The datetime when this commit was pushed.
+	PushedDate time.Time `json:"pushedDate"`
+	// The datetime when this commit was committed.
+	CommittedDate time.Time `json:"committedDate"`
+	// Check and Status rollup information for this commit.
+	StatusCheckRollup *StatusCheckRollup `json:"statusCheckRollup"`
+}
+
+// StatusCheckRollup includes the requested fields of the GraphQL type
+// StatusCheckRollup.
+type StatusCheckRollup struct {
+	// The combined status for the commit.
+	State StatusState `json:"state"`
+}
+
+// TimelineItems includes the requested fields of the GraphQL type
+// PullRequestTimelineItemsConnection.
+type TimelineItems struct {
+	// A list of nodes.
+	Nodes []TimelineItem `json:"nodes"`
+}
+
+// TimelineItem includes the requested fields of the GraphQL union
+// PullRequestTimelineItems. Only ReviewRequestedEvents are asked for.
+type TimelineItem struct {
+	Typename string `json:"__typename"`
+	// Identifies the date and time when the object was created.
+	CreatedAt time.Time `json:"createdAt"`
+	// Identifies the date and time when the object was last updated.
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ReviewRequests includes the requested fields of the GraphQL type
+// ReviewRequestConnection.
+type ReviewRequests struct {
+	// A list of nodes.
+	Nodes []ReviewRequest `json:"nodes"`
+}
+
+// ReviewRequest includes the requested fields of the GraphQL type
+// ReviewRequest.
+type ReviewRequest struct {
+	// The reviewer that is requested.
+	RequestedReviewer RequestedReviewer `json:"requestedReviewer"`
+}
+
+// RequestedReviewer includes the requested fields of the GraphQL union
+// RequestedReviewer, which is a User, Team or Mannequin. Teams have a
+// Slug, the others a Login.
+type RequestedReviewer struct {
+	Typename string `json:"__typename"`
+	Login    string `json:"login,omitempty"`
+	Slug     string `json:"slug,omitempty"`
+}
+
+// Name is the reviewer's login, or the team's slug.
+func (v *RequestedReviewer) Name() string {
+	if v.Login != "" {
+		return v.Login
+	}
+	return v.Slug
 }
 
 // Author includes the requested fields of the GraphQL type User.
@@ -133,6 +518,14 @@ func (v *Edge) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// MarshalJSON is the inverse of UnmarshalJSON, so an Edge can make a
+// round trip, e.g. through a cache.
+func (v Edge) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Node Item `json:"node"`
+	}{v.Node})
+}
+
 // Connection includes the requested fields of the GraphQL type SearchResultItemConnection.
 // The GraphQL type's documentation follows.
 //